@@ -7,5 +7,10 @@ import (
 )
 
 func (app *SequencerApplication) CheckTx(ctx context.Context, tx *types.RequestCheckTx) (*types.ResponseCheckTx, error) {
-	return &types.ResponseCheckTx{Code: types.CodeTypeOK}, nil
+	res := &types.ResponseCheckTx{Code: types.CodeTypeOK}
+	if res.IsErr() {
+		// the codespace names the reason for a rejection
+		app.metrics.CheckTxRejects.With("reason", res.Codespace).Add(1)
+	}
+	return res, nil
 }
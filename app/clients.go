@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	cmtlog "github.com/cometbft/cometbft/libs/log"
+	"github.com/go-kit/kit/metrics"
+)
+
+// tcpEstablished is the state of an established connection in /proc/net/tcp.
+const tcpEstablished = "01"
+
+// v4MappedPrefix starts the /proc/net/tcp6 form of an IPv4-mapped address,
+// whose last word is the IPv4 address as /proc/net/tcp writes it.
+const v4MappedPrefix = "0000000000000000FFFF0000"
+
+// procNetTCP are the tables of the TCP connections of the network namespace.
+var procNetTCP = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+
+// procSelfFD lists the file descriptors of this process.
+const procSelfFD = "/proc/self/fd"
+
+// tcpConn is an established connection of a /proc/net/tcp table.
+type tcpConn struct {
+	local, remote string // HEXIP:HEXPORT, with IPv4-mapped addresses as IPv4
+	localPort     uint16
+	remotePort    uint16
+	inode         uint64
+}
+
+// CountStreamClients returns the established TCP connections to port, except
+// those this process opened, such as the gateway's upstreams and the health
+// checks. The datastreamer library does not expose its clients, so they are
+// counted from the kernel's connection tables, which only Linux has.
+func CountStreamClients(port uint16) (int, error) {
+	var conns []tcpConn
+	for i, path := range procNetTCP {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) && i > 0 {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to open connection table: %w", err)
+		}
+		table, err := readTCPTable(f)
+		f.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		conns = append(conns, table...)
+	}
+	own, err := ownSockets()
+	if err != nil {
+		return 0, err
+	}
+	return countClients(conns, port, own), nil
+}
+
+// readTCPTable returns the established connections of a /proc/net/tcp table:
+// a header line, then one connection per line with its local and remote
+// addresses in the second and third fields, its state in the fourth and its
+// socket inode in the tenth.
+func readTCPTable(r io.Reader) ([]tcpConn, error) {
+	var conns []tcpConn
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpEstablished {
+			continue
+		}
+		local, localPort, err := parseTCPAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		remote, remotePort, err := parseTCPAddr(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inode %q", fields[9])
+		}
+		conns = append(conns, tcpConn{local: local, remote: remote, localPort: localPort, remotePort: remotePort, inode: inode})
+	}
+	return conns, scanner.Err()
+}
+
+func parseTCPAddr(s string) (string, uint16, error) {
+	ip, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid address %q", s)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid address %q", s)
+	}
+	ip = strings.TrimPrefix(ip, v4MappedPrefix)
+	return ip + ":" + hexPort, uint16(port), nil
+}
+
+// countClients counts the connections accepted on port whose other end is not
+// a socket in own.
+func countClients(conns []tcpConn, port uint16, own map[uint64]bool) int {
+	// the local addresses of the connections this process made to port
+	self := make(map[string]bool)
+	for _, c := range conns {
+		if c.remotePort == port && own[c.inode] {
+			self[c.local] = true
+		}
+	}
+	var n int
+	for _, c := range conns {
+		if c.localPort == port && !self[c.remote] {
+			n++
+		}
+	}
+	return n
+}
+
+// ownSockets returns the inodes of the sockets of this process.
+func ownSockets() (map[uint64]bool, error) {
+	fds, err := os.ReadDir(procSelfFD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file descriptors: %w", err)
+	}
+	own := make(map[uint64]bool)
+	for _, fd := range fds {
+		link, err := os.Readlink(procSelfFD + "/" + fd.Name())
+		if err != nil {
+			continue // closed since it was listed
+		}
+		if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+			if n, err := strconv.ParseUint(strings.TrimSuffix(inode, "]"), 10, 64); err == nil {
+				own[n] = true
+			}
+		}
+	}
+	return own, nil
+}
+
+// WatchStreamClients sets gauge to the count of clients of the stream server
+// on port every interval until ctx is done. It gives up, logging why, if they
+// cannot be counted.
+func WatchStreamClients(ctx context.Context, port uint16, interval time.Duration, gauge metrics.Gauge, logger cmtlog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := CountStreamClients(port)
+		if err != nil {
+			logger.Info("Not counting stream clients", "err", err)
+			return
+		}
+		gauge.Set(float64(n))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountClients(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1AF4 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:D431 0100007F:1AF4 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:D432 0100007F:1AF4 01 00000000:00000000 00:00000000 00000000     0        0 3 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:1AF4 0100007F:D433 06 00000000:00000000 00:00000000 00000000     0        0 0 3 0000000000000000
+`
+	// the server ends, accepted by a dual-stack listener
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000100007F:1AF4 0000000000000000FFFF00000100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 4 1 0000000000000000 20 4 30 10 -1
+   1: 0000000000000000FFFF00000100007F:1AF4 0000000000000000FFFF00000100007F:D432 01 00000000:00000000 00:00000000 00000000     0        0 5 1 0000000000000000 20 4 30 10 -1
+`
+	conns, err := readTCPTable(strings.NewReader(tcp))
+	require.NoError(t, err)
+	conns6, err := readTCPTable(strings.NewReader(tcp6))
+	require.NoError(t, err)
+	conns = append(conns, conns6...)
+	require.Len(t, conns, 4)
+
+	// both connections to 6900 come from outside the process
+	assert.Equal(t, 2, countClients(conns, 6900, map[uint64]bool{4: true, 5: true}))
+	// the process made the one from port D431 itself
+	assert.Equal(t, 1, countClients(conns, 6900, map[uint64]bool{2: true, 4: true, 5: true}))
+}
+
+func TestCountStreamClients(t *testing.T) {
+	if addr := os.Getenv("DSEQ_DIAL_ADDR"); addr != "" {
+		// connect from another process, until stdin closes
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+		os.Stdout.WriteString("connected\n")
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+		return
+	}
+	if _, err := os.Stat(procNetTCP[0]); err != nil {
+		t.Skip("no connection table:", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// connections of this process are not clients
+	self, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer self.Close()
+	require.Eventually(t, func() bool {
+		n, err := CountStreamClients(port)
+		return err == nil && n == 0
+	}, time.Second, 10*time.Millisecond)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCountStreamClients$")
+	cmd.Env = append(os.Environ(), "DSEQ_DIAL_ADDR="+l.Addr().String())
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	defer func() {
+		stdin.Close()
+		_ = cmd.Wait()
+	}()
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "connected\n", line)
+
+	require.Eventually(t, func() bool {
+		n, err := CountStreamClients(port)
+		return err == nil && n == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultStreamPort is the port of a node's data stream server unless configured.
+	DefaultStreamPort = 6900
+	// DefaultHealth is the address a node serves its health checks on unless configured.
+	DefaultHealth = ":26680"
+	// DefaultMaxBlockAge is how old a node's last block may be for it to be ready.
+	DefaultMaxBlockAge = time.Minute
+)
+
+// Config is the dseq section of a node's config.toml.
+type Config struct {
+	StreamPort uint16 `mapstructure:"stream_port"` // port of the data stream server
+	Gateway    string `mapstructure:"gateway"`     // address to serve the stream over WebSocket and SSE on, if any
+	Health     string `mapstructure:"health"`      // address to serve /healthz and /readyz on, if any
+
+	GatewayOrigins []string      `mapstructure:"gateway_origins"` // origins of other web pages allowed to open gateway streams
+	MaxBlockAge    time.Duration `mapstructure:"max_block_age"`   // oldest last block of a ready node, 0 to not check
+}
+
+// DefaultConfig returns the dseq configuration of a new node.
+func DefaultConfig() Config {
+	return Config{
+		StreamPort:  DefaultStreamPort,
+		Health:      DefaultHealth,
+		MaxBlockAge: DefaultMaxBlockAge,
+	}
+}
+
+// ValidateBasic checks the configuration for values that cannot work.
+func (c Config) ValidateBasic() error {
+	if c.StreamPort == 0 {
+		return fmt.Errorf("stream_port cannot be 0")
+	}
+	if c.MaxBlockAge < 0 {
+		return fmt.Errorf("max_block_age cannot be negative")
+	}
+	return nil
+}
+
+var configTemplate = template.Must(template.New("dseq").Parse(`
+#######################################################
+###                 dseq Options                    ###
+#######################################################
+[dseq]
+
+# Port of the data stream server
+stream_port = {{ .StreamPort }}
+
+# Address to serve the stream over WebSocket and SSE on, such as ":8080".
+# Leave empty to not serve it.
+gateway = {{ printf "%q" .Gateway }}
+
+# Origins of web pages, such as "https://app.example.com", allowed to open
+# gateway streams besides pages served by the gateway itself. "*" allows any.
+gateway_origins = [{{ range $i, $o := .GatewayOrigins }}{{ if $i }}, {{ end }}{{ printf "%q" $o }}{{ end }}]
+
+# Address to serve the /healthz and /readyz checks on, such as ":26680".
+# Leave empty to not serve them.
+health = {{ printf "%q" .Health }}
+
+# How old the last block may be for the node to be ready, such as "1m".
+# A node without empty blocks and with an empty mempool is ready however old
+# its last block is. Set to "0s" to not check.
+max_block_age = "{{ .MaxBlockAge }}"
+`))
+
+// WriteTOML writes the configuration as the dseq section of config.toml.
+func (c Config) WriteTOML(w io.Writer) error {
+	if err := configTemplate.Execute(w, c); err != nil {
+		return fmt.Errorf("failed to write dseq config: %w", err)
+	}
+	return nil
+}
@@ -2,15 +2,21 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/cometbft/cometbft/abci/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 )
 
 func (app *SequencerApplication) InitChain(_ context.Context, chain *types.RequestInitChain) (*types.ResponseInitChain, error) {
 	app.logger.Info("initializing chain", "chain-id", chain.ChainId, "initial-height", chain.InitialHeight)
+	if _, err := ParseGenesisState(chain.AppStateBytes); err != nil {
+		return nil, fmt.Errorf("invalid genesis: %w", err)
+	}
 	return &types.ResponseInitChain{}, nil
 }
 
@@ -22,6 +28,9 @@ func (app *SequencerApplication) PrepareProposal(_ context.Context, proposal *ty
 		txs[i], txs[j] = txs[j], txs[i]
 	})
 
+	app.metrics.ProposalTxs.Observe(float64(len(txs)))
+	app.metrics.ProposalBytes.Observe(float64(txBytes(txs)))
+
 	return &types.ResponsePrepareProposal{
 		Txs: txs,
 	}, nil
@@ -34,18 +43,28 @@ func (app *SequencerApplication) ProcessProposal(_ context.Context, proposal *ty
 }
 
 const (
-	EtL2BlockStart datastreamer.EntryType = 1 // EtL2BlockStart entry type
-	EtL2Tx         datastreamer.EntryType = 2 // EtL2Tx entry type
-	EtL2BlockEnd   datastreamer.EntryType = 3 // EtL2BlockEnd entry type
-	StSequencer                           = 1 // StSequencer sequencer stream type
+	EtL2BlockStart datastreamer.EntryType = 1                       // EtL2BlockStart entry type
+	EtL2Tx         datastreamer.EntryType = 2                       // EtL2Tx entry type
+	EtL2BlockEnd   datastreamer.EntryType = 3                       // EtL2BlockEnd entry type
+	EtBookmark     datastreamer.EntryType = datastreamer.EtBookmark // EtBookmark entry type
+	StSequencer                           = 1                       // StSequencer sequencer stream type
 )
 
 func (app *SequencerApplication) FinalizeBlock(_ context.Context, block *types.RequestFinalizeBlock) (*types.ResponseFinalizeBlock, error) {
+	began := time.Now()
+	defer func() {
+		app.metrics.FinalizeBlockSeconds.Observe(time.Since(began).Seconds())
+	}()
+	app.metrics.BlockTxs.Observe(float64(len(block.Txs)))
+	app.metrics.BlockBytes.Observe(float64(txBytes(block.Txs)))
+
 	app.stagedTxs = make([][]byte, 0)
 
 	respTxs := make([]*types.ExecTxResult, len(block.Txs))
 
 	if len(block.Txs) == 0 {
+		app.state.Height = block.Height
+		app.state.LastBlockHash = block.Hash
 		return &types.ResponseFinalizeBlock{TxResults: respTxs, AppHash: app.state.Hash()}, nil
 	}
 
@@ -54,9 +73,19 @@ func (app *SequencerApplication) FinalizeBlock(_ context.Context, block *types.R
 		return nil, err
 	}
 
-	blockNum, err := app.dataServer.AddStreamEntry(EtL2BlockStart, []byte{})
+	bookmark := BlockBookmark(uint64(block.Height))
+	if _, err = app.dataServer.AddStreamBookmark(bookmark); err != nil {
+		return nil, app.rollback(err)
+	}
+
+	start := BlockStart{
+		Height: uint64(block.Height),
+		Time:   block.Time,
+		Hash:   common.BytesToHash(block.Hash),
+	}
+	blockNum, err := app.dataServer.AddStreamEntry(EtL2BlockStart, start.Encode())
 	if err != nil {
-		return nil, err
+		return nil, app.rollback(err)
 	}
 
 	for i, tx := range block.Txs {
@@ -67,40 +96,53 @@ func (app *SequencerApplication) FinalizeBlock(_ context.Context, block *types.R
 			Code: 0, // 0 == ok
 			// TODO: potentially attach tx level events here as well
 		}
-		app.state.Size++
 
 		_, err := app.dataServer.AddStreamEntry(EtL2Tx, tx)
 		if err != nil {
-			err = app.dataServer.RollbackAtomicOp()
-			if err != nil {
-				return nil, errors.Cause(err)
-			}
-			return nil, err
+			return nil, app.rollback(err)
 		}
 	}
 
-	_, err = app.dataServer.AddStreamEntry(EtL2BlockEnd, []byte{})
+	end := BlockEnd{
+		Height: uint64(block.Height),
+		NumTxs: uint64(len(block.Txs)),
+		Size:   uint64(app.state.Size) + uint64(len(block.Txs)),
+		TxRoot: TxRoot(block.Txs),
+	}
+	_, err = app.dataServer.AddStreamEntry(EtL2BlockEnd, end.Encode())
 	if err != nil {
 		app.logger.Error("error finalizing block to stream", "block", blockNum, "error", err)
-		err = app.dataServer.RollbackAtomicOp()
-		if err != nil {
-			return nil, errors.Cause(err)
-		}
-		return nil, err
+		return nil, app.rollback(err)
 	}
 
+	committing := time.Now()
 	err = app.dataServer.CommitAtomicOp()
 	if err != nil {
 		return nil, err
 	}
+	app.metrics.StreamCommitSeconds.Observe(time.Since(committing).Seconds())
 
+	app.state.Size = int64(end.Size)
 	app.state.Height = block.Height
+	app.state.LastBlockHash = block.Hash
+	app.state.LastBookmark = bookmark
+	app.state.Entries = app.dataServer.GetHeader().TotalEntries
+	app.metrics.StreamEntries.Set(float64(app.state.Entries))
 
 	response := &types.ResponseFinalizeBlock{TxResults: respTxs, AppHash: app.state.Hash()} // hash should include tx hashes
 
 	return response, nil
 }
 
+// rollback discards the block being written to the stream and returns the
+// error that caused it, or the rollback error if that failed too.
+func (app *SequencerApplication) rollback(cause error) error {
+	if err := app.dataServer.RollbackAtomicOp(); err != nil {
+		return errors.Cause(err)
+	}
+	return cause
+}
+
 func (app *SequencerApplication) ExtendVote(_ context.Context, _ *types.RequestExtendVote) (*types.ResponseExtendVote, error) {
 	return &types.ResponseExtendVote{}, nil
 }
@@ -114,6 +156,7 @@ func (app *SequencerApplication) Commit(_ context.Context, _ *types.RequestCommi
 		app.logger.Error("app failed to save state", "error", err)
 		return nil, err
 	}
+	app.publishStatus()
 
 	return &types.ResponseCommit{}, nil
 }
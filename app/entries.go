@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	blockStartSize = 8 + 8 + common.HashLength     // height, time, block hash
+	blockEndSize   = 8 + 8 + 8 + common.HashLength // height, txs, size, tx root
+	bookmarkSize   = 1 + 8                         // type, height
+
+	BookmarkTypeBlock byte = 1 // BookmarkTypeBlock marks the start of a block in the stream
+)
+
+// ErrLegacyStream is returned for block start and end entries without a payload,
+// which dseq wrote before blocks carried their metadata and bookmarks. Streams
+// in that format cannot be read by this version.
+var ErrLegacyStream = errors.New("stream was written in the legacy format without block metadata")
+
+// BlockStart is the payload of an EtL2BlockStart entry.
+type BlockStart struct {
+	Height uint64      `json:"height"`
+	Time   time.Time   `json:"time"`
+	Hash   common.Hash `json:"hash"` // CometBFT block hash
+}
+
+// Encode serializes the block start to its stream representation.
+func (b BlockStart) Encode() []byte {
+	data := make([]byte, 0, blockStartSize)
+	data = binary.BigEndian.AppendUint64(data, b.Height)
+	data = binary.BigEndian.AppendUint64(data, uint64(b.Time.UnixNano()))
+	return append(data, b.Hash.Bytes()...)
+}
+
+// DecodeBlockStart parses the data of an EtL2BlockStart entry.
+func DecodeBlockStart(data []byte) (BlockStart, error) {
+	if len(data) == 0 {
+		return BlockStart{}, ErrLegacyStream
+	}
+	if len(data) != blockStartSize {
+		return BlockStart{}, fmt.Errorf("invalid block start length %d, expected %d", len(data), blockStartSize)
+	}
+	return BlockStart{
+		Height: binary.BigEndian.Uint64(data[0:8]),
+		Time:   time.Unix(0, int64(binary.BigEndian.Uint64(data[8:16]))).UTC(),
+		Hash:   common.BytesToHash(data[16:]),
+	}, nil
+}
+
+// BlockEnd is the payload of an EtL2BlockEnd entry.
+type BlockEnd struct {
+	Height uint64      `json:"height"`
+	NumTxs uint64      `json:"txs"`
+	Size   uint64      `json:"size"`    // total number of txs sequenced up to and including this block
+	TxRoot common.Hash `json:"tx_root"` // see TxRoot
+}
+
+// Encode serializes the block end to its stream representation.
+func (b BlockEnd) Encode() []byte {
+	data := make([]byte, 0, blockEndSize)
+	data = binary.BigEndian.AppendUint64(data, b.Height)
+	data = binary.BigEndian.AppendUint64(data, b.NumTxs)
+	data = binary.BigEndian.AppendUint64(data, b.Size)
+	return append(data, b.TxRoot.Bytes()...)
+}
+
+// DecodeBlockEnd parses the data of an EtL2BlockEnd entry.
+func DecodeBlockEnd(data []byte) (BlockEnd, error) {
+	if len(data) == 0 {
+		return BlockEnd{}, ErrLegacyStream
+	}
+	if len(data) != blockEndSize {
+		return BlockEnd{}, fmt.Errorf("invalid block end length %d, expected %d", len(data), blockEndSize)
+	}
+	return BlockEnd{
+		Height: binary.BigEndian.Uint64(data[0:8]),
+		NumTxs: binary.BigEndian.Uint64(data[8:16]),
+		Size:   binary.BigEndian.Uint64(data[16:24]),
+		TxRoot: common.BytesToHash(data[24:]),
+	}, nil
+}
+
+// TxHash returns the hash CometBFT uses to identify a tx.
+func TxHash(tx []byte) common.Hash {
+	return common.BytesToHash(tmhash.Sum(tx))
+}
+
+// TxRoot hashes the concatenated hashes of the txs of a block, in stream order.
+func TxRoot(txs [][]byte) common.Hash {
+	h := tmhash.New()
+	for _, tx := range txs {
+		h.Write(tmhash.Sum(tx))
+	}
+	return common.BytesToHash(h.Sum(nil))
+}
+
+// BlockBookmark returns the bookmark written in front of the block at height.
+func BlockBookmark(height uint64) []byte {
+	data := make([]byte, 0, bookmarkSize)
+	data = append(data, BookmarkTypeBlock)
+	return binary.BigEndian.AppendUint64(data, height)
+}
+
+// DecodeBookmark returns the height a block bookmark points at.
+func DecodeBookmark(data []byte) (uint64, error) {
+	if len(data) != bookmarkSize || data[0] != BookmarkTypeBlock {
+		return 0, fmt.Errorf("invalid block bookmark %x", data)
+	}
+	return binary.BigEndian.Uint64(data[1:]), nil
+}
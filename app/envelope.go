@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// envelopeMagic prefixes txs that carry an Envelope. Txs without it are opaque bytes.
+	envelopeMagic = []byte{0xd5, 0xe1}
+
+	// envelopeDomain prefixes the signed bytes of an envelope, so a producer's
+	// signature cannot be taken for one over another message of the same key.
+	envelopeDomain = []byte("dseq-envelope-v1")
+
+	// ErrNotEnvelope is returned when decoding a tx that is not an envelope.
+	ErrNotEnvelope = errors.New("tx is not an envelope")
+)
+
+// Envelope wraps a tx payload with the namespace and producer it belongs to.
+type Envelope struct {
+	Namespace string         `json:"namespace"`
+	Producer  common.Address `json:"producer"`
+	Nonce     uint64         `json:"nonce"`
+	Payload   hexutil.Bytes  `json:"payload"`
+	Signature hexutil.Bytes  `json:"signature,omitempty"` // optional, see Sign
+}
+
+// Encode serializes the envelope into tx bytes.
+func (e *Envelope) Encode() ([]byte, error) {
+	body, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return append(bytes.Clone(envelopeMagic), body...), nil
+}
+
+// IsEnvelope reports whether tx carries an envelope.
+func IsEnvelope(tx []byte) bool {
+	return bytes.HasPrefix(tx, envelopeMagic)
+}
+
+// DecodeEnvelope parses an envelope from tx bytes. It returns ErrNotEnvelope for opaque txs.
+func DecodeEnvelope(tx []byte) (*Envelope, error) {
+	if !IsEnvelope(tx) {
+		return nil, ErrNotEnvelope
+	}
+	var e Envelope
+	if err := rlp.DecodeBytes(tx[len(envelopeMagic):], &e); err != nil {
+		return nil, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+	return &e, nil
+}
+
+// SigningHash is the hash a producer signs: everything but the signature,
+// after the envelope domain.
+func (e *Envelope) SigningHash() common.Hash {
+	body, _ := rlp.EncodeToBytes([]interface{}{e.Namespace, e.Producer, e.Nonce, []byte(e.Payload)})
+	return crypto.Keccak256Hash(envelopeDomain, body)
+}
+
+// Sign sets the producer to the key's address and signs the envelope.
+func (e *Envelope) Sign(key *ecdsa.PrivateKey) error {
+	e.Producer = crypto.PubkeyToAddress(key.PublicKey)
+	sig, err := crypto.Sign(e.SigningHash().Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("failed to sign envelope: %w", err)
+	}
+	e.Signature = sig
+	return nil
+}
+
+// Signed reports whether the envelope carries a signature.
+func (e *Envelope) Signed() bool {
+	return len(e.Signature) > 0
+}
+
+// Verify checks that the signature was made by the producer. Unsigned envelopes verify.
+func (e *Envelope) Verify() error {
+	if !e.Signed() {
+		return nil
+	}
+	pub, err := crypto.SigToPub(e.SigningHash().Bytes(), e.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid envelope signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != e.Producer {
+		return fmt.Errorf("envelope signed by %s, not producer %s", signer, e.Producer)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	env := &Envelope{Namespace: "orders", Nonce: 3, Payload: []byte("hello")}
+	require.NoError(t, env.Sign(key))
+
+	tx, err := env.Encode()
+	require.NoError(t, err)
+	assert.True(t, IsEnvelope(tx))
+
+	decoded, err := DecodeEnvelope(tx)
+	require.NoError(t, err)
+	assert.Equal(t, env, decoded)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), decoded.Producer)
+	assert.NoError(t, decoded.Verify())
+
+	decoded.Nonce++
+	assert.Error(t, decoded.Verify())
+}
+
+func TestEnvelopeSigningHashHasDomain(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	env := &Envelope{Namespace: "orders", Producer: crypto.PubkeyToAddress(key.PublicKey), Nonce: 3, Payload: []byte("hello")}
+
+	// a signature over the same fields without the domain does not verify
+	body, err := rlp.EncodeToBytes([]interface{}{env.Namespace, env.Producer, env.Nonce, []byte(env.Payload)})
+	require.NoError(t, err)
+	env.Signature, err = crypto.Sign(crypto.Keccak256(body), key)
+	require.NoError(t, err)
+	assert.Error(t, env.Verify())
+
+	require.NoError(t, env.Sign(key))
+	assert.NoError(t, env.Verify())
+}
+
+func TestDecodeEnvelopeOpaqueTx(t *testing.T) {
+	_, err := DecodeEnvelope([]byte{0xde, 0xad, 0xbe, 0xef})
+	assert.ErrorIs(t, err, ErrNotEnvelope)
+}
+
+func TestUnsignedEnvelopeVerifies(t *testing.T) {
+	env := &Envelope{Namespace: "orders", Payload: []byte("hello")}
+	assert.False(t, env.Signed())
+	assert.NoError(t, env.Verify())
+}
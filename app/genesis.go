@@ -0,0 +1,45 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// GenesisState is the dseq section, app_state, of a genesis file. Unset
+// fields are not checked, so that genesis files without one still work.
+type GenesisState struct {
+	AppVersion uint64 `json:"app_version"` // of the app the chain was created for
+	StreamType uint64 `json:"stream_type"` // of the data streams the nodes write
+}
+
+// DefaultGenesisState returns the app state of a new chain.
+func DefaultGenesisState() GenesisState {
+	return GenesisState{AppVersion: AppVersion, StreamType: StSequencer}
+}
+
+// Validate checks that this app can run a chain with the state.
+func (g GenesisState) Validate() error {
+	if g.AppVersion != 0 && g.AppVersion != AppVersion {
+		return fmt.Errorf("genesis is for app version %d, this is version %d", g.AppVersion, AppVersion)
+	}
+	if g.StreamType != 0 && g.StreamType != StSequencer {
+		return fmt.Errorf("genesis is for stream type %d, expected %d", g.StreamType, StSequencer)
+	}
+	return nil
+}
+
+// ParseGenesisState parses and validates the app state of a genesis file. An
+// empty state is the zero state.
+func ParseGenesisState(data []byte) (GenesisState, error) {
+	var g GenesisState
+	if len(bytes.TrimSpace(data)) == 0 {
+		return g, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&g); err != nil {
+		return g, fmt.Errorf("failed to parse app state: %w", err)
+	}
+	return g, g.Validate()
+}
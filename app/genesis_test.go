@@ -0,0 +1,69 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGenesisState(t *testing.T) {
+	data, err := json.Marshal(DefaultGenesisState())
+	require.NoError(t, err)
+	g, err := ParseGenesisState(data)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultGenesisState(), g)
+
+	for _, ok := range []string{"", "{}", `{"app_version":1}`} {
+		_, err := ParseGenesisState([]byte(ok))
+		assert.NoError(t, err, ok)
+	}
+	for _, bad := range []string{"[]", `{"app_version":2}`, `{"stream_type":7}`, `{"unknown":1}`} {
+		_, err := ParseGenesisState([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestInitChainChecksAppState(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	_, err := app.InitChain(context.Background(), &types.RequestInitChain{ChainId: "test", AppStateBytes: []byte(`{"app_version":1,"stream_type":1}`)})
+	assert.NoError(t, err)
+	_, err = app.InitChain(context.Background(), &types.RequestInitChain{ChainId: "test", AppStateBytes: []byte(`{"app_version":99}`)})
+	assert.ErrorContains(t, err, "app version 99")
+}
+
+func TestConfigTOML(t *testing.T) {
+	cfg := Config{StreamPort: 7000, Gateway: ":8080", GatewayOrigins: []string{"https://a.example", "*"}, Health: "127.0.0.1:9000", MaxBlockAge: 90 * time.Second}
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteTOML(&buf))
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(&buf))
+	read := DefaultConfig()
+	require.NoError(t, v.UnmarshalKey("dseq", &read))
+	assert.Equal(t, cfg, read)
+
+	// quotes and backslashes stay valid TOML
+	cfg = Config{StreamPort: 7000, Gateway: `host"name:8080`, GatewayOrigins: []string{`https://"a".example`, `C:\origin`}, Health: `\\health`}
+	buf.Reset()
+	require.NoError(t, cfg.WriteTOML(&buf))
+	v = viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(&buf))
+	read = DefaultConfig()
+	require.NoError(t, v.UnmarshalKey("dseq", &read))
+	assert.Equal(t, cfg, read)
+
+	assert.NoError(t, DefaultConfig().ValidateBasic())
+	assert.Error(t, Config{}.ValidateBasic())
+	assert.Error(t, Config{StreamPort: 1, MaxBlockAge: -time.Second}.ValidateBasic())
+}
@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+)
+
+// CaughtUp checks that CometBFT is not catching up with the network, as
+// reported by catchingUp.
+func CaughtUp(catchingUp func() bool) Check {
+	return Check{Name: "consensus", Run: func(context.Context) error {
+		if catchingUp() {
+			return errors.New("catching up with the network")
+		}
+		return nil
+	}}
+}
+
+// StreamServing checks that the stream server at addr (host:port) accepts
+// clients and answers them.
+func StreamServing(addr string) Check {
+	return Check{Name: "stream", Run: func(ctx context.Context) error {
+		if _, err := readHeader(ctx, addr); err != nil {
+			return fmt.Errorf("stream server not answering: %w", err)
+		}
+		return nil
+	}}
+}
+
+// StateMatchesStream checks that the app state, as reported by status, is at
+// the last block in the stream at addr. The stream may be one block ahead,
+// which the app is committing.
+func StateMatchesStream(addr string, status func() app.Status) Check {
+	return Check{Name: "state", Run: func(ctx context.Context) error {
+		st := status()
+		entries, height, err := lastBlock(ctx, addr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entries == st.Entries && height == st.LastBlockHeight:
+			return nil
+		case entries > st.Entries && height == uint64(st.Height)+1:
+			return nil
+		default:
+			return fmt.Errorf("state at height %d with %d entries, stream at block %d with %d entries",
+				st.Height, st.Entries, height, entries)
+		}
+	}}
+}
+
+// lastBlock returns the number of entries in the stream at addr and the
+// height of its last block, 0 if it has none.
+func lastBlock(ctx context.Context, addr string) (entries, height uint64, err error) {
+	header, err := readHeader(ctx, addr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read stream header: %w", err)
+	}
+	if header.TotalEntries == 0 {
+		return 0, 0, nil
+	}
+
+	// blocks are committed to the stream whole, so the last entry ends one
+	c, err := client.New(addr, client.WithFromEntry(header.TotalEntries-1))
+	if err != nil {
+		return 0, 0, err
+	}
+	var end app.BlockEnd
+	err = c.Run(ctx, func(e *datastreamer.FileEntry) error {
+		if e.Type != app.EtL2BlockEnd {
+			return fmt.Errorf("stream entry %d is not a block end", e.Number)
+		}
+		decoded, err := app.DecodeBlockEnd(e.Data)
+		if err != nil {
+			return err
+		}
+		end = decoded
+		return client.ErrStop
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read last stream block: %w", err)
+	}
+	return header.TotalEntries, end.Height, nil
+}
+
+// streamHeader is the header of a stream, read once for the checks of a probe.
+type streamHeader struct {
+	once   sync.Once
+	header datastreamer.HeaderEntry
+	err    error
+}
+
+// readHeader returns the header of the stream at addr. The checks of a probe
+// share the first one read.
+func readHeader(ctx context.Context, addr string) (datastreamer.HeaderEntry, error) {
+	p, ok := ctx.Value(probeKey{}).(*probe)
+	if !ok {
+		return fetchHeader(ctx, addr)
+	}
+	p.mu.Lock()
+	h, ok := p.headers[addr]
+	if !ok {
+		h = &streamHeader{}
+		p.headers[addr] = h
+	}
+	p.mu.Unlock()
+	h.once.Do(func() { h.header, h.err = fetchHeader(ctx, addr) })
+	return h.header, h.err
+}
+
+func fetchHeader(ctx context.Context, addr string) (datastreamer.HeaderEntry, error) {
+	c, err := client.New(addr)
+	if err != nil {
+		return datastreamer.HeaderEntry{}, err
+	}
+	return c.Header(ctx)
+}
+
+// RecentBlock checks that the last block, whose time lastBlock returns, is no
+// older than maxAge, unless the node is idle: it makes no blocks without txs,
+// and has none to sequence. lastBlock returns false before the first block.
+func RecentBlock(lastBlock func() (time.Time, bool), idle func() bool, maxAge time.Duration) Check {
+	return Check{Name: "last_block", Run: func(context.Context) error {
+		if idle() {
+			return nil
+		}
+		at, ok := lastBlock()
+		if !ok {
+			return errors.New("no blocks yet")
+		}
+		if age := time.Since(at); age > maxAge {
+			return fmt.Errorf("last block is %s old, more than %s", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,113 @@
+// Package health serves the liveness and readiness of a dseq node over HTTP,
+// for orchestrators and load balancers.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds how long the checks of a readiness probe may take.
+const checkTimeout = 5 * time.Second
+
+// Check is a condition a node must meet to be ready. Run returns why the
+// node does not meet it.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// probeKey is the context key of the probe whose checks are running.
+type probeKey struct{}
+
+// probe is what the checks of one readiness probe share, so that they don't
+// each open a session with the stream server: its headers, by address.
+type probe struct {
+	mu      sync.Mutex
+	headers map[string]*streamHeader
+}
+
+// Readiness is the outcome of all checks.
+type Readiness struct {
+	Ready  bool     `json:"ready"`
+	Checks []Result `json:"checks"`
+}
+
+// Server serves the health of a node.
+//
+//	GET /healthz  200 while the node serves requests
+//	GET /readyz   200 if every check passes, 503 otherwise, with the Readiness as JSON
+type Server struct {
+	checks []Check
+}
+
+// NewServer returns a server that is ready when all checks pass.
+func NewServer(checks ...Check) *Server {
+	return &Server{checks: checks}
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/readyz", s.serveReady)
+	return mux
+}
+
+// Ready runs the checks concurrently and returns their results, in the order
+// of the checks.
+func (s *Server) Ready(ctx context.Context) Readiness {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, probeKey{}, &probe{headers: make(map[string]*streamHeader)})
+
+	r := Readiness{Ready: true, Checks: make([]Result, len(s.checks))}
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			r.Checks[i] = Result{Name: check.Name, OK: true}
+			if err := check.Run(ctx); err != nil {
+				r.Checks[i] = Result{Name: check.Name, Error: err.Error()}
+			}
+		}(i, check)
+	}
+	wg.Wait()
+	for _, c := range r.Checks {
+		r.Ready = r.Ready && c.OK
+	}
+	return r
+}
+
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{"ok"})
+}
+
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	readiness := s.Ready(r.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,156 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestStream serves a stream of blocks at heights 1 and 3, and returns
+// its address and number of entries.
+func setupTestStream(t *testing.T) (string, uint64) {
+	t.Helper()
+	ds := streamtest.NewServer(t)
+	for _, height := range []uint64{1, 3} {
+		ds.AddBlock(height, height, []byte("tx"))
+	}
+	return ds.Addr, ds.GetHeader().TotalEntries
+}
+
+// countingProxy forwards connections to addr and counts them.
+func countingProxy(t *testing.T, addr string) (string, *atomic.Int32) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	var conns atomic.Int32
+	go func() {
+		for {
+			in, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			out, err := net.Dial("tcp", addr)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			go func() { io.Copy(out, in); out.Close() }()
+			go func() { io.Copy(in, out); in.Close() }()
+		}
+	}()
+	return l.Addr().String(), &conns
+}
+
+func run(check Check) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	return check.Run(ctx)
+}
+
+func TestServer(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	srv := httptest.NewServer(NewServer(
+		Check{Name: "ok", Run: func(context.Context) error { return nil }},
+		Check{Name: "flaky", Run: func(context.Context) error {
+			if failing.Load() {
+				return errors.New("not yet")
+			}
+			return nil
+		}},
+	).Handler())
+	defer srv.Close()
+
+	readyz := func() (int, Readiness) {
+		resp, err := http.Get(srv.URL + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var r Readiness
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		return resp.StatusCode, r
+	}
+
+	status, r := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, Readiness{Checks: []Result{
+		{Name: "ok", OK: true},
+		{Name: "flaky", Error: "not yet"},
+	}}, r)
+
+	failing.Store(false)
+	status, r = readyz()
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, r.Ready)
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCaughtUp(t *testing.T) {
+	assert.NoError(t, run(CaughtUp(func() bool { return false })))
+	assert.Error(t, run(CaughtUp(func() bool { return true })))
+}
+
+func TestStreamServing(t *testing.T) {
+	addr, _ := setupTestStream(t)
+	assert.NoError(t, run(StreamServing(addr)))
+	assert.Error(t, run(StreamServing("127.0.0.1:"+strconv.Itoa(streamtest.FreePort(t)))))
+}
+
+func TestReadySharesStreamHeader(t *testing.T) {
+	stream, entries := setupTestStream(t)
+	addr, conns := countingProxy(t, stream)
+	status := func() app.Status { return app.Status{Height: 3, Entries: entries, LastBlockHeight: 3} }
+	srv := NewServer(StreamServing(addr), StateMatchesStream(addr, status))
+
+	r := srv.Ready(context.Background())
+	assert.True(t, r.Ready, r)
+	// one session for the header, one for the last block
+	assert.Equal(t, int32(2), conns.Load())
+}
+
+func TestStateMatchesStream(t *testing.T) {
+	addr, entries := setupTestStream(t)
+	check := func(st app.Status) error {
+		return run(StateMatchesStream(addr, func() app.Status { return st }))
+	}
+
+	assert.NoError(t, check(app.Status{Height: 3, Entries: entries, LastBlockHeight: 3}))
+	// empty blocks after the last one in the stream
+	assert.NoError(t, check(app.Status{Height: 5, Entries: entries, LastBlockHeight: 3}))
+	// the block at height 3 is being committed
+	assert.NoError(t, check(app.Status{Height: 2, Entries: entries / 2, LastBlockHeight: 1}))
+
+	assert.ErrorContains(t, check(app.Status{Height: 1, Entries: entries / 2, LastBlockHeight: 1}), "stream at block 3")
+	assert.Error(t, check(app.Status{Height: 4, Entries: entries + 4, LastBlockHeight: 4}))
+}
+
+func TestRecentBlock(t *testing.T) {
+	busy := func() bool { return false }
+	at := func(t time.Time) func() (time.Time, bool) {
+		return func() (time.Time, bool) { return t, true }
+	}
+
+	assert.NoError(t, run(RecentBlock(at(time.Now()), busy, time.Minute)))
+	assert.ErrorContains(t, run(RecentBlock(at(time.Now().Add(-time.Hour)), busy, time.Minute)), "1h0m0s old")
+	assert.Error(t, run(RecentBlock(func() (time.Time, bool) { return time.Time{}, false }, busy, time.Minute)))
+	assert.NoError(t, run(RecentBlock(at(time.Now().Add(-time.Hour)), func() bool { return true }, time.Minute)))
+}
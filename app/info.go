@@ -3,15 +3,40 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/cometbft/cometbft/abci/types"
 	"github.com/cometbft/cometbft/version"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
+// Info reports the application state CometBFT uses in its handshake. The state is
+// reconciled with the stream first, so a crash between committing a block to the
+// stream and saving the state doesn't make CometBFT replay that block twice.
 func (app *SequencerApplication) Info(_ context.Context, _ *types.RequestInfo) (*types.ResponseInfo, error) {
+	if err := app.reconcile(); err != nil {
+		app.logger.Error("state does not match stream", "error", err)
+		return nil, err
+	}
+	app.publishStatus()
+	app.metrics.StreamEntries.Set(float64(app.state.Entries))
+
 	data, _ := json.Marshal(struct {
-		Size   int64 `json:"size"`
-		Height int64 `json:"height"`
-	}{app.state.Size, app.state.Height})
+		Size          int64         `json:"size"`
+		Height        int64         `json:"height"`
+		Entries       uint64        `json:"entries"`
+		LastBlockHash hexutil.Bytes `json:"last_block_hash"`
+		LastBookmark  hexutil.Bytes `json:"last_bookmark"`
+		StateVersion  int           `json:"state_version"`
+	}{
+		app.state.Size,
+		app.state.Height,
+		app.state.Entries,
+		app.state.LastBlockHash,
+		app.state.LastBookmark,
+		app.state.Version,
+	})
 	return &types.ResponseInfo{
 		Data:             string(data),
 		Version:          version.ABCIVersion,
@@ -20,3 +45,86 @@ func (app *SequencerApplication) Info(_ context.Context, _ *types.RequestInfo) (
 		LastBlockAppHash: app.state.Hash(),
 	}, nil
 }
+
+// reconcile brings the state up to date with the blocks committed to the stream.
+func (app *SequencerApplication) reconcile() error {
+	header := app.dataServer.GetHeader()
+
+	if err := app.checkStreamFormat(header); err != nil {
+		return err
+	}
+
+	upgraded := false
+	if app.state.Version < StateVersion {
+		// older states did not track stream entries, so the state is rebuilt from
+		// the whole stream
+		app.logger.Info("upgrading state", "from-version", app.state.Version, "to-version", StateVersion)
+		app.state.Version = StateVersion
+		app.state.Size, app.state.Height, app.state.Entries = 0, 0, 0
+		upgraded = true
+	}
+
+	if header.TotalEntries == app.state.Entries {
+		if upgraded {
+			return app.state.Save()
+		}
+		return nil
+	}
+	if header.TotalEntries < app.state.Entries {
+		return fmt.Errorf("stream has %d entries, state expects %d", header.TotalEntries, app.state.Entries)
+	}
+
+	app.logger.Info("recovering state from stream", "from-entry", app.state.Entries, "to-entry", header.TotalEntries)
+
+	for num := app.state.Entries; num < header.TotalEntries; num++ {
+		entry, err := app.dataServer.GetEntry(num)
+		if err != nil {
+			return fmt.Errorf("failed to read stream entry %d: %w", num, err)
+		}
+
+		switch entry.Type {
+		case EtBookmark:
+			app.state.LastBookmark = entry.Data
+		case EtL2BlockStart:
+			start, err := DecodeBlockStart(entry.Data)
+			if err != nil {
+				return fmt.Errorf("stream entry %d: %w", num, err)
+			}
+			app.state.LastBlockHash = start.Hash.Bytes()
+		case EtL2BlockEnd:
+			end, err := DecodeBlockEnd(entry.Data)
+			if err != nil {
+				return fmt.Errorf("stream entry %d: %w", num, err)
+			}
+			if int64(end.Height) > app.state.Height {
+				app.state.Height = int64(end.Height)
+			}
+			app.state.Size = int64(end.Size)
+			app.state.Entries = num + 1
+		}
+	}
+
+	if app.state.Entries != header.TotalEntries {
+		return fmt.Errorf("stream ends inside a block after entry %d", app.state.Entries)
+	}
+
+	return app.state.Save()
+}
+
+// checkStreamFormat fails if the stream was written in the legacy format, whose
+// first entry is a block start without a payload. This version cannot read it,
+// so the operator has to delete it, along with the app state, to have CometBFT
+// replay its blocks into a new stream.
+func (app *SequencerApplication) checkStreamFormat(header datastreamer.HeaderEntry) error {
+	if header.TotalEntries == 0 {
+		return nil
+	}
+	first, err := app.dataServer.GetEntry(0)
+	if err != nil {
+		return fmt.Errorf("failed to read stream entry 0: %w", err)
+	}
+	if first.Type == EtL2BlockStart && len(first.Data) == 0 {
+		return fmt.Errorf("%w: delete dseq.bin, dseq.db and state.db from the node home to rebuild them from CometBFT's blocks on restart", ErrLegacyStream)
+	}
+	return nil
+}
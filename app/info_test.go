@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoRecoversStateFromStream(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	txs := [][]byte{[]byte("tx1"), []byte("tx2"), []byte("tx3")}
+	_, err := app.FinalizeBlock(context.Background(), &types.RequestFinalizeBlock{
+		Height: 7,
+		Time:   time.Unix(1700000000, 0),
+		Hash:   []byte{0xab, 0xcd},
+		Txs:    txs,
+	})
+	require.NoError(t, err)
+
+	entries := app.dataServer.GetHeader().TotalEntries
+	assert.Equal(t, entries, app.state.Entries)
+
+	// simulate a crash after the stream commit but before the state was saved
+	app.state.Size, app.state.Height, app.state.Entries = 0, 0, 0
+	app.state.LastBlockHash, app.state.LastBookmark = nil, nil
+
+	info, err := app.Info(context.Background(), &types.RequestInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), info.LastBlockHeight)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 3}, info.LastBlockAppHash)
+
+	var data struct {
+		Size          int64  `json:"size"`
+		Entries       uint64 `json:"entries"`
+		LastBookmark  string `json:"last_bookmark"`
+		StateVersion  int    `json:"state_version"`
+		LastBlockHash string `json:"last_block_hash"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(info.Data), &data))
+	assert.Equal(t, int64(3), data.Size)
+	assert.Equal(t, entries, data.Entries)
+	assert.Equal(t, "0x010000000000000007", data.LastBookmark)
+	assert.Equal(t, StateVersion, data.StateVersion)
+	assert.Equal(t, "0x000000000000000000000000000000000000000000000000000000000000abcd", data.LastBlockHash)
+}
+
+func TestInfoUpgradesUnversionedState(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	_, err := app.FinalizeBlock(context.Background(), &types.RequestFinalizeBlock{
+		Height: 4,
+		Time:   time.Unix(1700000000, 0),
+		Hash:   []byte{0x01},
+		Txs:    [][]byte{[]byte("tx1"), []byte("tx2")},
+	})
+	require.NoError(t, err)
+	entries := app.dataServer.GetHeader().TotalEntries
+
+	// a state saved before versioning, which did not count stream entries
+	app.state.Version, app.state.Entries = 0, 0
+	app.state.LastBlockHash, app.state.LastBookmark = nil, nil
+
+	info, err := app.Info(context.Background(), &types.RequestInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), info.LastBlockHeight)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 2}, info.LastBlockAppHash)
+	assert.Equal(t, StateVersion, app.state.Version)
+	assert.Equal(t, entries, app.state.Entries)
+	assert.Equal(t, "0x010000000000000004", app.state.LastBookmark.String())
+
+	// the upgraded state is saved
+	raw, err := app.state.db.Get(stateKey)
+	require.NoError(t, err)
+	var saved State
+	require.NoError(t, json.Unmarshal(raw, &saved))
+	assert.Equal(t, StateVersion, saved.Version)
+	assert.Equal(t, entries, saved.Entries)
+}
+
+func TestInfoRejectsStreamBehindState(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	app.state.Entries = app.dataServer.GetHeader().TotalEntries + 1
+
+	_, err := app.Info(context.Background(), &types.RequestInfo{})
+	assert.Error(t, err)
+}
+
+func TestInfoRejectsLegacyStream(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	// blocks were framed by empty start and end entries before they carried metadata
+	require.NoError(t, app.dataServer.StartAtomicOp())
+	for _, e := range []struct {
+		typ  datastreamer.EntryType
+		data []byte
+	}{{EtL2BlockStart, []byte{}}, {EtL2Tx, []byte("tx")}, {EtL2BlockEnd, []byte{}}} {
+		_, err := app.dataServer.AddStreamEntry(e.typ, e.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.dataServer.CommitAtomicOp())
+	app.state.Version = 0
+
+	_, err := app.Info(context.Background(), &types.RequestInfo{})
+	assert.ErrorIs(t, err, ErrLegacyStream)
+	assert.ErrorContains(t, err, "dseq.bin")
+
+	_, err = DecodeBlockStart(nil)
+	assert.ErrorIs(t, err, ErrLegacyStream)
+}
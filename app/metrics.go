@@ -0,0 +1,104 @@
+package app
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSubsystem is the subsystem of the metrics of the sequencer, which are
+// served with CometBFT's under the same namespace.
+const MetricsSubsystem = "dseq"
+
+// Metrics are the metrics of the sequencing pipeline.
+type Metrics struct {
+	// Txs in each finalized block.
+	BlockTxs metrics.Histogram
+	// Bytes of the txs in each finalized block.
+	BlockBytes metrics.Histogram
+	// Time spent in FinalizeBlock, including writing the block to the stream.
+	FinalizeBlockSeconds metrics.Histogram
+	// Time spent committing a block's entries to the stream.
+	StreamCommitSeconds metrics.Histogram
+	// Txs rejected by CheckTx, labeled by reason.
+	CheckTxRejects metrics.Counter
+	// Entries in the stream.
+	StreamEntries metrics.Gauge
+	// Height of the last block in the app state.
+	StateHeight metrics.Gauge
+	// Txs in each proposal this node prepared.
+	ProposalTxs metrics.Histogram
+	// Bytes of the txs in each proposal this node prepared.
+	ProposalBytes metrics.Histogram
+}
+
+// PrometheusMetrics returns metrics registered with Prometheus' default
+// registry, labeled with labelsAndValues, like CometBFT's.
+func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
+	labels := []string{}
+	for i := 0; i < len(labelsAndValues); i += 2 {
+		labels = append(labels, labelsAndValues[i])
+	}
+	histogram := func(name, help string, buckets []float64) metrics.Histogram {
+		return prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      name,
+			Help:      help,
+			Buckets:   buckets,
+		}, labels).With(labelsAndValues...)
+	}
+	gauge := func(name, help string) metrics.Gauge {
+		return prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      name,
+			Help:      help,
+		}, labels).With(labelsAndValues...)
+	}
+	txBuckets := stdprometheus.ExponentialBuckets(1, 4, 10)
+	byteBuckets := stdprometheus.ExponentialBuckets(256, 4, 10)
+	secondBuckets := stdprometheus.ExponentialBuckets(0.0005, 2, 14)
+
+	return &Metrics{
+		BlockTxs:             histogram("block_txs", "Txs in each finalized block.", txBuckets),
+		BlockBytes:           histogram("block_bytes", "Bytes of the txs in each finalized block.", byteBuckets),
+		FinalizeBlockSeconds: histogram("finalize_block_seconds", "Time spent in FinalizeBlock.", secondBuckets),
+		StreamCommitSeconds:  histogram("stream_commit_seconds", "Time spent committing a block to the stream.", secondBuckets),
+		CheckTxRejects: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "check_tx_rejects",
+			Help:      "Txs rejected by CheckTx, by reason.",
+		}, append(labels, "reason")).With(labelsAndValues...),
+		StreamEntries: gauge("stream_entries", "Entries in the stream."),
+		StateHeight:   gauge("state_height", "Height of the last block in the app state."),
+		ProposalTxs:   histogram("proposal_txs", "Txs in each proposal prepared by this node.", txBuckets),
+		ProposalBytes: histogram("proposal_bytes", "Bytes of the txs in each proposal prepared by this node.", byteBuckets),
+	}
+}
+
+// NopMetrics returns metrics that record nothing.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		BlockTxs:             discard.NewHistogram(),
+		BlockBytes:           discard.NewHistogram(),
+		FinalizeBlockSeconds: discard.NewHistogram(),
+		StreamCommitSeconds:  discard.NewHistogram(),
+		CheckTxRejects:       discard.NewCounter(),
+		StreamEntries:        discard.NewGauge(),
+		StateHeight:          discard.NewGauge(),
+		ProposalTxs:          discard.NewHistogram(),
+		ProposalBytes:        discard.NewHistogram(),
+	}
+}
+
+// txBytes returns the total size of txs.
+func txBytes(txs [][]byte) int {
+	var n int
+	for _, tx := range txs {
+		n += len(tx)
+	}
+	return n
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	app, cleanup := setupTestSequencer(t)
+	defer cleanup()
+
+	var (
+		blockTxs, blockBytes       = generic.NewSimpleHistogram(), generic.NewSimpleHistogram()
+		proposalTxs, proposalBytes = generic.NewSimpleHistogram(), generic.NewSimpleHistogram()
+		finalize, streamCommit     = generic.NewSimpleHistogram(), generic.NewSimpleHistogram()
+		entries, height            = generic.NewGauge("entries"), generic.NewGauge("height")
+	)
+	require.NoError(t, WithMetrics(&Metrics{
+		BlockTxs:             blockTxs,
+		BlockBytes:           blockBytes,
+		FinalizeBlockSeconds: finalize,
+		StreamCommitSeconds:  streamCommit,
+		CheckTxRejects:       generic.NewCounter("rejects"),
+		StreamEntries:        entries,
+		StateHeight:          height,
+		ProposalTxs:          proposalTxs,
+		ProposalBytes:        proposalBytes,
+	})(app))
+	ctx := context.Background()
+
+	txs := [][]byte{[]byte("tx1"), []byte("tx22"), []byte("tx333")}
+	_, err := app.PrepareProposal(ctx, &types.RequestPrepareProposal{Txs: txs})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, proposalTxs.ApproximateMovingAverage())
+	assert.Equal(t, 12.0, proposalBytes.ApproximateMovingAverage())
+
+	_, err = app.FinalizeBlock(ctx, &types.RequestFinalizeBlock{Height: 5, Time: time.Now(), Hash: []byte{1}, Txs: txs})
+	require.NoError(t, err)
+	_, err = app.Commit(ctx, &types.RequestCommit{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3.0, blockTxs.ApproximateMovingAverage())
+	assert.Equal(t, 12.0, blockBytes.ApproximateMovingAverage())
+	assert.Positive(t, finalize.ApproximateMovingAverage())
+	assert.Positive(t, streamCommit.ApproximateMovingAverage())
+	assert.Equal(t, float64(app.dataServer.GetHeader().TotalEntries), entries.Value())
+	assert.Equal(t, 5.0, height.Value())
+	assert.Equal(t, Status{Height: 5, Entries: app.state.Entries, LastBlockHeight: 5}, app.Status())
+
+	assert.Error(t, WithMetrics(nil)(app))
+}
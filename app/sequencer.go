@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/cometbft/cometbft/abci/types"
@@ -22,6 +23,8 @@ type SequencerApplication struct {
 	addr      common.Address
 	state     *State
 	stagedTxs [][]byte
+	metrics   *Metrics
+	status    atomic.Pointer[Status]
 
 	// TODO: Store and maintain validator info for helping restarts, and punishing misbehavior
 	// valAddrToPubKeyMap map[string]crypto.PublicKey
@@ -76,6 +79,17 @@ func WithDataServer(ds *datastreamer.StreamServer) Option {
 	}
 }
 
+// WithMetrics sets the metrics the application records, NopMetrics by default.
+func WithMetrics(metrics *Metrics) Option {
+	return func(app *SequencerApplication) error {
+		if metrics == nil {
+			return fmt.Errorf("metrics cannot be nil")
+		}
+		app.metrics = metrics
+		return nil
+	}
+}
+
 var _ types.Application = (*SequencerApplication)(nil)
 
 // NewSequencer constructs a SequencerApplication with the given logger and options.
@@ -86,7 +100,8 @@ func NewSequencer(logger log.Logger, opts ...Option) (*SequencerApplication, err
 	}
 
 	app := &SequencerApplication{
-		logger: logger,
+		logger:  logger,
+		metrics: NopMetrics(),
 	}
 
 	for _, opt := range opts {
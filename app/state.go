@@ -7,6 +7,13 @@ import (
 	"os"
 
 	db "github.com/cometbft/cometbft-db"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// StateVersion is the schema version of the persisted state. States saved
+	// before versioning was introduced load as version 0.
+	StateVersion = 1
 )
 
 var (
@@ -20,6 +27,11 @@ type State struct {
 	// This is used for the appHash
 	Size   int64 `json:"size"`
 	Height int64 `json:"height"`
+
+	Version       int           `json:"version"`
+	Entries       uint64        `json:"entries"` // stream entries written up to Height
+	LastBlockHash hexutil.Bytes `json:"last_block_hash,omitempty"`
+	LastBookmark  hexutil.Bytes `json:"last_bookmark,omitempty"`
 }
 
 // NewState creates a new State instance with the given path.
@@ -46,6 +58,8 @@ func NewState(path string) (*State, error) {
 		if err := json.Unmarshal(stateBytes, state); err != nil {
 			return nil, fmt.Errorf("failed to read current state: %w", err)
 		}
+	} else {
+		state.Version = StateVersion
 	}
 
 	return state, nil
@@ -67,8 +81,14 @@ func (s *State) Save() error {
 
 // Hash returns a byte slice representing the state's hash.
 func (s *State) Hash() []byte {
+	return AppHash(s.Size)
+}
+
+// AppHash returns the hash of a state that has sequenced size txs. It does not
+// depend on the content of the txs.
+func AppHash(size int64) []byte {
 	bytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(bytes, uint64(s.Size))
+	binary.BigEndian.PutUint64(bytes, uint64(size))
 	return bytes
 }
 
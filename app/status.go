@@ -0,0 +1,28 @@
+package app
+
+// Status is a snapshot of the committed application state, which can be read
+// while blocks are being finalized.
+type Status struct {
+	Height          int64  // height of the last committed block
+	Entries         uint64 // stream entries written up to Height
+	LastBlockHeight uint64 // height of the last block in the stream, 0 if it has none
+}
+
+// Status returns the state as of the last commit, or of the handshake before
+// the first one.
+func (app *SequencerApplication) Status() Status {
+	if s := app.status.Load(); s != nil {
+		return *s
+	}
+	return Status{}
+}
+
+// publishStatus makes the current state the one Status returns.
+func (app *SequencerApplication) publishStatus() {
+	s := Status{Height: app.state.Height, Entries: app.state.Entries}
+	if height, err := DecodeBookmark(app.state.LastBookmark); err == nil {
+		s.LastBlockHeight = height
+	}
+	app.status.Store(&s)
+	app.metrics.StateHeight.Set(float64(s.Height))
+}
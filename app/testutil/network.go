@@ -0,0 +1,434 @@
+// Package testutil runs networks of dseq validators inside a test process, so
+// that consensus-level behavior can be tested with go test.
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	"github.com/cometbft/cometbft/libs/log"
+	"github.com/cometbft/cometbft/node"
+	"github.com/cometbft/cometbft/p2p"
+	"github.com/cometbft/cometbft/privval"
+	"github.com/cometbft/cometbft/proxy"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	sm "github.com/cometbft/cometbft/state"
+	"github.com/cometbft/cometbft/types"
+	cmttime "github.com/cometbft/cometbft/types/time"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// pollInterval is how often the heights of nodes are checked while waiting.
+const pollInterval = 10 * time.Millisecond
+
+// Network is a network of dseq validators running in the test process. Every
+// node has equal voting power, so the network makes progress while more than
+// two thirds of its nodes run.
+type Network struct {
+	ChainID string
+	Nodes   []*Node
+
+	genesis   *types.GenesisDoc
+	logger    log.Logger
+	configure []func(*config.Config)
+}
+
+// Node is a validator of a Network. Its CometBFT databases are in memory and
+// survive a Stop, so that Start resumes where the node left off.
+type Node struct {
+	Name       string
+	Home       string
+	ID         p2p.ID
+	Validator  common.Address
+	RPCURL     string // http://host:port of the CometBFT RPC
+	StreamAddr string // host:port of the data stream
+
+	net     *Network
+	cfg     *config.Config
+	pv      *privval.FilePV
+	nodeKey *p2p.NodeKey
+	stream  *datastreamer.StreamServer
+
+	mtx   sync.Mutex
+	dbs   map[string]dbm.DB
+	node  *node.Node
+	state *app.State
+	rpc   *rpchttp.HTTP
+}
+
+// Option configures a Network.
+type Option func(*Network) error
+
+// WithLogger sets the logger of the nodes, which log nothing by default.
+func WithLogger(logger log.Logger) Option {
+	return func(net *Network) error {
+		if logger == nil {
+			return fmt.Errorf("logger cannot be nil")
+		}
+		net.logger = logger
+		return nil
+	}
+}
+
+// WithConfig changes the CometBFT configuration of every node, after the
+// network has set its ports and peers.
+func WithConfig(fn func(*config.Config)) Option {
+	return func(net *Network) error {
+		net.configure = append(net.configure, fn)
+		return nil
+	}
+}
+
+// NewNetwork starts a network of n validators, with fast consensus timeouts
+// and no empty blocks, and stops it when the test ends.
+func NewNetwork(t testing.TB, n int, opts ...Option) *Network {
+	t.Helper()
+	require.Positive(t, n, "a network needs at least one validator")
+
+	net := &Network{
+		ChainID: "dseq-test",
+		logger:  log.NewNopLogger(),
+	}
+	for _, opt := range opts {
+		require.NoError(t, opt(net), "failed to apply option")
+	}
+
+	ports := freePorts(t, 3*n)
+	dir := t.TempDir()
+	validators := make([]types.GenesisValidator, n)
+	for i := range validators {
+		node, err := net.newNode(filepath.Join(dir, fmt.Sprintf("node%d", i)), fmt.Sprintf("node%d", i), ports[3*i:3*i+3])
+		require.NoError(t, err)
+		net.Nodes = append(net.Nodes, node)
+
+		pubKey, err := node.pv.GetPubKey()
+		require.NoError(t, err)
+		validators[i] = types.GenesisValidator{Address: pubKey.Address(), PubKey: pubKey, Power: 10, Name: node.Name}
+	}
+
+	appState, err := json.Marshal(app.DefaultGenesisState())
+	require.NoError(t, err)
+	net.genesis = &types.GenesisDoc{
+		ChainID:         net.ChainID,
+		GenesisTime:     cmttime.Now(),
+		ConsensusParams: types.DefaultConsensusParams(),
+		Validators:      validators,
+		AppState:        appState,
+	}
+	require.NoError(t, net.genesis.ValidateAndComplete())
+
+	for _, node := range net.Nodes {
+		var peers []string
+		for _, peer := range net.Nodes {
+			if peer != node {
+				peers = append(peers, fmt.Sprintf("%s@%s", peer.ID, strings.TrimPrefix(peer.cfg.P2P.ListenAddress, "tcp://")))
+			}
+		}
+		node.cfg.P2P.PersistentPeers = strings.Join(peers, ",")
+		for _, fn := range net.configure {
+			fn(node.cfg)
+		}
+	}
+
+	t.Cleanup(func() {
+		if err := net.Stop(); err != nil {
+			t.Errorf("failed to stop network: %v", err)
+		}
+	})
+	for _, node := range net.Nodes {
+		require.NoError(t, node.Start())
+	}
+	return net
+}
+
+// freePorts returns n distinct ports that were free when it was called.
+func freePorts(t testing.TB, n int) []int {
+	t.Helper()
+	ports := make([]int, n)
+	for i := range ports {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		// held until all ports are picked, so that none is picked twice
+		defer l.Close()
+		ports[i] = l.Addr().(*net.TCPAddr).Port
+	}
+	return ports
+}
+
+// newNode creates the home, keys and stream of a node listening on ports,
+// which are its p2p, RPC and stream ports.
+func (net *Network) newNode(home, name string, ports []int) (*Node, error) {
+	cfg := config.TestConfig()
+	cfg.SetRoot(home)
+	cfg.Moniker = name
+	cfg.Consensus.CreateEmptyBlocks = false
+	cfg.P2P.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", ports[0])
+	cfg.P2P.AddrBookStrict = false
+	cfg.RPC.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", ports[1])
+	cfg.RPC.GRPCListenAddress = ""
+
+	for _, dir := range []string{filepath.Dir(cfg.PrivValidatorKeyFile()), filepath.Dir(cfg.PrivValidatorStateFile())} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	pv := privval.GenFilePV(cfg.PrivValidatorKeyFile(), cfg.PrivValidatorStateFile())
+	pv.Save()
+	nodeKey := &p2p.NodeKey{PrivKey: ed25519.GenPrivKey()}
+
+	stream, err := datastreamer.NewServer(uint16(ports[2]), 1, 1, datastreamer.StreamType(app.StSequencer), filepath.Join(home, "dseq.bin"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream server: %w", err)
+	}
+	// the stream server cannot be stopped, so it serves for as long as the
+	// test process runs, including while the node is stopped
+	if err := stream.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start stream server: %w", err)
+	}
+
+	return &Node{
+		Name:       name,
+		Home:       home,
+		ID:         nodeKey.ID(),
+		Validator:  common.BytesToAddress(pv.GetAddress().Bytes()),
+		RPCURL:     strings.Replace(cfg.RPC.ListenAddress, "tcp://", "http://", 1),
+		StreamAddr: fmt.Sprintf("127.0.0.1:%d", ports[2]),
+		net:        net,
+		cfg:        cfg,
+		pv:         pv,
+		nodeKey:    nodeKey,
+		stream:     stream,
+		dbs:        make(map[string]dbm.DB),
+	}, nil
+}
+
+// Stop stops every running node.
+func (net *Network) Stop() error {
+	var firstErr error
+	for _, node := range net.Nodes {
+		if err := node.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WaitForHeight waits until every running node has committed the block at height.
+func (net *Network) WaitForHeight(ctx context.Context, height int64) error {
+	for _, node := range net.Nodes {
+		if !node.Running() {
+			continue
+		}
+		if err := node.WaitForHeight(ctx, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Height returns the highest height committed by a node.
+func (net *Network) Height() int64 {
+	var height int64
+	for _, node := range net.Nodes {
+		height = max(height, node.Height())
+	}
+	return height
+}
+
+// Start starts the node, or restarts it after Stop with the blocks, app state
+// and stream it had.
+func (n *Node) Start() error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.node != nil {
+		return fmt.Errorf("node %s is already running", n.Name)
+	}
+
+	// connections kept alive by an earlier client would still reach the
+	// stopped node's RPC, so every start gets a new client
+	rpc, err := rpchttp.New(n.RPCURL, "/websocket")
+	if err != nil {
+		return fmt.Errorf("failed to create rpc client: %w", err)
+	}
+	state, err := app.NewState(n.cfg.DBDir())
+	if err != nil {
+		return fmt.Errorf("failed to create state: %w", err)
+	}
+	logger := n.net.logger.With("node", n.Name)
+	sequencer, err := app.NewSequencer(
+		logger,
+		app.WithIdentity(n.Name),
+		app.WithAddress(n.Validator),
+		app.WithState(state),
+		app.WithDataServer(n.stream),
+	)
+	if err != nil {
+		state.Close()
+		return fmt.Errorf("failed to create sequencer: %w", err)
+	}
+
+	cmtNode, err := node.NewNode(
+		n.cfg,
+		n.pv,
+		n.nodeKey,
+		proxy.NewLocalClientCreator(sequencer),
+		func() (*types.GenesisDoc, error) { return n.net.genesis, nil },
+		n.dbProvider,
+		node.DefaultMetricsProvider(n.cfg.Instrumentation),
+		logger,
+	)
+	if err != nil {
+		state.Close()
+		return fmt.Errorf("failed to create node %s: %w", n.Name, err)
+	}
+	if err := cmtNode.Start(); err != nil {
+		state.Close()
+		return fmt.Errorf("failed to start node %s: %w", n.Name, err)
+	}
+	n.node, n.state, n.rpc = cmtNode, state, rpc
+	return nil
+}
+
+// dbProvider provides the node's in-memory databases, creating them the first
+// time the node starts.
+func (n *Node) dbProvider(ctx *config.DBContext) (dbm.DB, error) {
+	db, ok := n.dbs[ctx.ID]
+	if !ok {
+		db = dbm.NewMemDB()
+		n.dbs[ctx.ID] = db
+	}
+	return db, nil
+}
+
+// Stop stops the node, if it is running. Its stream stays readable.
+func (n *Node) Stop() error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.node == nil {
+		return nil
+	}
+
+	var firstErr error
+	if err := n.node.Stop(); err != nil {
+		firstErr = fmt.Errorf("failed to stop node %s: %w", n.Name, err)
+	}
+	n.node.Wait()
+	if err := n.state.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	n.node, n.state = nil, nil
+	return firstErr
+}
+
+// Running returns whether the node is running.
+func (n *Node) Running() bool {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.node != nil
+}
+
+// Height returns the height of the last block the node committed, including
+// while it is stopped.
+func (n *Node) Height() int64 {
+	n.mtx.Lock()
+	db, ok := n.dbs["state"]
+	n.mtx.Unlock()
+	if !ok {
+		return 0
+	}
+	state, err := sm.NewStore(db, sm.StoreOptions{}).Load()
+	if err != nil {
+		return 0
+	}
+	return state.LastBlockHeight
+}
+
+// WaitForHeight waits until the node has committed the block at height.
+func (n *Node) WaitForHeight(ctx context.Context, height int64) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for n.Height() < height {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %s is at height %d, not %d: %w", n.Name, n.Height(), height, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Submit broadcasts tx through the node's RPC and waits for it to be
+// committed. It fails if the tx is rejected.
+func (n *Node) Submit(ctx context.Context, tx []byte) (*coretypes.ResultBroadcastTxCommit, error) {
+	rpc := n.RPC()
+	if rpc == nil {
+		return nil, fmt.Errorf("node %s is not running", n.Name)
+	}
+	res, err := rpc.BroadcastTxCommit(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to broadcast tx to %s: %w", n.Name, err)
+	}
+	if res.CheckTx.IsErr() {
+		return res, fmt.Errorf("tx rejected by CheckTx on %s: %s", n.Name, res.CheckTx.Log)
+	}
+	if res.TxResult.IsErr() {
+		return res, fmt.Errorf("tx rejected on %s: %s", n.Name, res.TxResult.Log)
+	}
+	return res, nil
+}
+
+// RPC returns a client of the node's CometBFT RPC, or nil if the node has
+// never started.
+func (n *Node) RPC() *rpchttp.HTTP {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.rpc
+}
+
+// StreamClient returns a client of the node's data stream.
+func (n *Node) StreamClient(opts ...client.Option) (*client.Client, error) {
+	return client.New(n.StreamAddr, append([]client.Option{client.WithBackoff(pollInterval, time.Second)}, opts...)...)
+}
+
+// StreamBlocks returns the blocks in the node's stream.
+func (n *Node) StreamBlocks(ctx context.Context) ([]*client.Block, error) {
+	c, err := n.StreamClient()
+	if err != nil {
+		return nil, err
+	}
+	header, err := c.Header(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream header of %s: %w", n.Name, err)
+	}
+	var blocks []*client.Block
+	if header.TotalEntries == 0 {
+		return blocks, nil
+	}
+	err = c.RunBlocks(ctx, func(b *client.Block) error {
+		blocks = append(blocks, b)
+		if b.LastEntry+1 >= header.TotalEntries {
+			return client.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream of %s: %w", n.Name, err)
+	}
+	return blocks, nil
+}
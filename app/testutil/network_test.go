@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// submit sends count txs, round robin to the running nodes, and returns the
+// highest height they were committed at.
+func submit(t *testing.T, ctx context.Context, net *Network, prefix string, count int) int64 {
+	t.Helper()
+	var running []*Node
+	for _, node := range net.Nodes {
+		if node.Running() {
+			running = append(running, node)
+		}
+	}
+	var height int64
+	for i := 0; i < count; i++ {
+		res, err := running[i%len(running)].Submit(ctx, []byte(fmt.Sprintf("%s-%d", prefix, i)))
+		require.NoError(t, err)
+		height = max(height, res.Height)
+	}
+	return height
+}
+
+// requireSameStreams asserts that every node streams the same blocks, and
+// returns them.
+func requireSameStreams(t *testing.T, ctx context.Context, net *Network) []*client.Block {
+	t.Helper()
+	want, err := net.Nodes[0].StreamBlocks(ctx)
+	require.NoError(t, err)
+	for _, node := range net.Nodes[1:] {
+		got, err := node.StreamBlocks(ctx)
+		require.NoError(t, err)
+		require.Equal(t, want, got, "stream of %s", node.Name)
+	}
+	return want
+}
+
+func countTxs(blocks []*client.Block) int {
+	var n int
+	for _, b := range blocks {
+		n += len(b.Txs)
+	}
+	return n
+}
+
+func TestNetwork(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	net := NewNetwork(t, 4)
+
+	height := submit(t, ctx, net, "tx", 20)
+	require.NoError(t, net.WaitForHeight(ctx, height))
+
+	blocks := requireSameStreams(t, ctx, net)
+	assert.Equal(t, 20, countTxs(blocks))
+	assert.Equal(t, uint64(20), blocks[len(blocks)-1].Size)
+	assert.LessOrEqual(t, int64(blocks[len(blocks)-1].Height), height)
+}
+
+func TestNetworkRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	net := NewNetwork(t, 4)
+
+	height := submit(t, ctx, net, "before", 5)
+	require.NoError(t, net.WaitForHeight(ctx, height))
+
+	stopped := net.Nodes[3]
+	require.NoError(t, stopped.Stop())
+	assert.False(t, stopped.Running())
+
+	// three of four validators still make progress
+	height = submit(t, ctx, net, "while-stopped", 5)
+	require.NoError(t, net.WaitForHeight(ctx, height))
+
+	require.NoError(t, stopped.Start())
+	require.NoError(t, stopped.WaitForHeight(ctx, height))
+	assert.Error(t, stopped.Start())
+
+	height = submit(t, ctx, net, "after", 5)
+	require.NoError(t, net.WaitForHeight(ctx, height))
+	assert.Equal(t, 15, countTxs(requireSameStreams(t, ctx, net)))
+}
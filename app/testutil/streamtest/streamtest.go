@@ -0,0 +1,77 @@
+// Package streamtest serves data streams written the way the sequencer writes
+// them, for tests of the packages that read them.
+package streamtest
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/stretchr/testify/require"
+)
+
+// FreePort returns a TCP port that was free when it was called.
+func FreePort(t testing.TB) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Server is a started data stream server, whose file is removed when the test
+// ends. Like every stream server, it serves until the test process exits.
+type Server struct {
+	*datastreamer.StreamServer
+	Addr string // host:port the stream is served on
+	Path string // path of the stream file
+
+	t testing.TB
+}
+
+// NewServer starts an empty stream on a free port.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	port := FreePort(t)
+	path := filepath.Join(t.TempDir(), "dseq.bin")
+	ds, err := datastreamer.NewServer(uint16(port), 1, 1, app.StSequencer, path, nil)
+	require.NoError(t, err)
+	require.NoError(t, ds.Start())
+	return &Server{StreamServer: ds, Addr: "127.0.0.1:" + strconv.Itoa(port), Path: path, t: t}
+}
+
+// AddBlock writes the block at height with txs, as FinalizeBlock does. size is
+// the number of txs sequenced up to and including the block. The block's time
+// is height seconds after the epoch.
+func (s *Server) AddBlock(height, size uint64, txs ...[]byte) {
+	s.t.Helper()
+	require.NoError(s.t, s.StartAtomicOp())
+	_, err := s.AddStreamBookmark(app.BlockBookmark(height))
+	require.NoError(s.t, err)
+	start := app.BlockStart{Height: height, Time: time.Unix(int64(height), 0)}
+	_, err = s.AddStreamEntry(app.EtL2BlockStart, start.Encode())
+	require.NoError(s.t, err)
+	for _, tx := range txs {
+		_, err = s.AddStreamEntry(app.EtL2Tx, tx)
+		require.NoError(s.t, err)
+	}
+	end := app.BlockEnd{Height: height, NumTxs: uint64(len(txs)), Size: size, TxRoot: app.TxRoot(txs)}
+	_, err = s.AddStreamEntry(app.EtL2BlockEnd, end.Encode())
+	require.NoError(s.t, err)
+	require.NoError(s.t, s.CommitAtomicOp())
+}
+
+// AddTxs writes txs outside of any block, which the sequencer never does.
+func (s *Server) AddTxs(txs ...[]byte) {
+	s.t.Helper()
+	require.NoError(s.t, s.StartAtomicOp())
+	for _, tx := range txs {
+		_, err := s.AddStreamEntry(app.EtL2Tx, tx)
+		require.NoError(s.t, err)
+	}
+	require.NoError(s.t, s.CommitAtomicOp())
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrFraming is matched by errors.Is for every FramingError.
+var ErrFraming = errors.New("invalid stream framing")
+
+// FramingError is returned when the stream entries do not form valid blocks.
+type FramingError struct {
+	Entry  uint64 // entry at which the framing broke
+	Reason string
+}
+
+func (e *FramingError) Error() string {
+	return fmt.Sprintf("invalid stream framing at entry %d: %s", e.Entry, e.Reason)
+}
+
+func (e *FramingError) Is(target error) bool {
+	return target == ErrFraming
+}
+
+// Block is a finalized block as it appears in the stream.
+type Block struct {
+	Height     uint64      `json:"height"`
+	Time       time.Time   `json:"time"`
+	Hash       common.Hash `json:"hash"`
+	Txs        []Tx        `json:"txs"`
+	Size       uint64      `json:"size"` // txs sequenced up to and including this block
+	FirstEntry uint64      `json:"first_entry"`
+	LastEntry  uint64      `json:"last_entry"`
+}
+
+// Tx is a tx of a block. Envelope is nil for opaque txs.
+type Tx struct {
+	Entry    uint64        `json:"entry"`
+	Hash     common.Hash   `json:"hash"`
+	Data     hexutil.Bytes `json:"data"`
+	Envelope *app.Envelope `json:"envelope,omitempty"`
+}
+
+// BlockFunc processes a block. Blocks are delivered in order, exactly once.
+type BlockFunc func(b *Block) error
+
+// RunBlocks delivers the blocks of the stream to fn, with the same stopping and
+// reconnection behavior as Run. Streaming that starts in the middle of a block
+// skips to the next one. A checkpoint is saved after each delivered block, and
+// Next points at the first entry of the next block when RunBlocks returns.
+func (c *Client) RunBlocks(ctx context.Context, fn BlockFunc) error {
+	a := &assembler{delivered: c.next}
+	if c.bookmark != nil {
+		// the first entry is the bookmark of the block at fromHeight
+		a.delivered = 0
+	}
+	err := c.run(ctx, false, func(e *datastreamer.FileEntry) error {
+		block, err := a.add(e)
+		if err != nil || block == nil {
+			return err
+		}
+		if block.Height >= c.fromHeight {
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+		a.delivered = block.LastEntry + 1
+		return c.saveCheckpoint(a.delivered)
+	})
+	c.next = a.delivered
+	return err
+}
+
+// Blocks streams blocks on the returned channel until ctx is done or streaming
+// fails. Both channels are closed when streaming stops; the error channel
+// first receives the error that stopped it, unless ctx was cancelled.
+func (c *Client) Blocks(ctx context.Context) (<-chan *Block, <-chan error) {
+	blocks := make(chan *Block)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(blocks)
+
+		err := c.RunBlocks(ctx, func(b *Block) error {
+			select {
+			case blocks <- b:
+				return nil
+			case <-ctx.Done():
+				return ErrStop
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return blocks, errs
+}
+
+// assembler groups stream entries into blocks.
+type assembler struct {
+	synced    bool    // whether the first block boundary has been seen
+	pending   *uint64 // bookmark entry preceding the next block start
+	block     *Block  // block being assembled
+	delivered uint64  // entry after the last delivered block
+}
+
+// add consumes an entry and returns the block it completes, if any.
+func (a *assembler) add(e *datastreamer.FileEntry) (*Block, error) {
+	if !a.synced {
+		if e.Type != app.EtBookmark && e.Type != app.EtL2BlockStart {
+			a.delivered = e.Number + 1
+			return nil, nil
+		}
+		a.synced = true
+	}
+
+	switch e.Type {
+	case app.EtBookmark:
+		if a.block != nil {
+			return nil, &FramingError{Entry: e.Number, Reason: fmt.Sprintf("bookmark inside block %d", a.block.Height)}
+		}
+		num := e.Number
+		a.pending = &num
+
+	case app.EtL2BlockStart:
+		if a.block != nil {
+			return nil, &FramingError{Entry: e.Number, Reason: fmt.Sprintf("block %d has no end", a.block.Height)}
+		}
+		start, err := app.DecodeBlockStart(e.Data)
+		if err != nil {
+			return nil, &FramingError{Entry: e.Number, Reason: err.Error()}
+		}
+		a.block = &Block{
+			Height:     start.Height,
+			Time:       start.Time,
+			Hash:       start.Hash,
+			Txs:        []Tx{},
+			FirstEntry: e.Number,
+		}
+		if a.pending != nil {
+			a.block.FirstEntry = *a.pending
+			a.pending = nil
+		}
+
+	case app.EtL2Tx:
+		if a.block == nil {
+			return nil, &FramingError{Entry: e.Number, Reason: "tx outside a block"}
+		}
+		tx := Tx{Entry: e.Number, Hash: app.TxHash(e.Data), Data: e.Data}
+		if env, err := app.DecodeEnvelope(e.Data); err == nil {
+			tx.Envelope = env
+		}
+		a.block.Txs = append(a.block.Txs, tx)
+
+	case app.EtL2BlockEnd:
+		if a.block == nil {
+			return nil, &FramingError{Entry: e.Number, Reason: "block end without block start"}
+		}
+		end, err := app.DecodeBlockEnd(e.Data)
+		if err != nil {
+			return nil, &FramingError{Entry: e.Number, Reason: err.Error()}
+		}
+		if err := a.check(end); err != nil {
+			return nil, &FramingError{Entry: e.Number, Reason: err.Error()}
+		}
+		block := a.block
+		block.Size = end.Size
+		block.LastEntry = e.Number
+		a.block = nil
+		return block, nil
+
+	default:
+		return nil, &FramingError{Entry: e.Number, Reason: fmt.Sprintf("unknown entry type %d", e.Type)}
+	}
+
+	return nil, nil
+}
+
+// check verifies that end closes the block being assembled.
+func (a *assembler) check(end app.BlockEnd) error {
+	if end.Height != a.block.Height {
+		return fmt.Errorf("block end for height %d closes block %d", end.Height, a.block.Height)
+	}
+	if end.NumTxs != uint64(len(a.block.Txs)) {
+		return fmt.Errorf("block %d ends after %d txs, block end counts %d", a.block.Height, len(a.block.Txs), end.NumTxs)
+	}
+	txs := make([][]byte, len(a.block.Txs))
+	for i, tx := range a.block.Txs {
+		txs[i] = tx.Data
+	}
+	if root := app.TxRoot(txs); root != end.TxRoot {
+		return fmt.Errorf("block %d tx root is %s, block end has %s", a.block.Height, root, end.TxRoot)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientBlocks(t *testing.T) {
+	s := setupTestServer(t, 0)
+	s.AddBlock(1, 2, []byte("a"), []byte("b"))
+	s.AddBlock(2, 3, []byte("c"))
+
+	// starting inside the first block skips to the second
+	c, err := New(s.Addr, WithFromEntry(3))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	blocks, errs := c.Blocks(ctx)
+	b := <-blocks
+	require.NotNil(t, b)
+	assert.Equal(t, uint64(2), b.Height)
+	assert.Equal(t, uint64(3), b.Size)
+	assert.Equal(t, uint64(5), b.FirstEntry)
+	assert.Equal(t, uint64(8), b.LastEntry)
+	require.Len(t, b.Txs, 1)
+	assert.Equal(t, []byte("c"), []byte(b.Txs[0].Data))
+	assert.Equal(t, app.TxHash([]byte("c")), b.Txs[0].Hash)
+
+	cancel()
+	for range blocks {
+	}
+	assert.NoError(t, <-errs)
+}
+
+func TestClientRunBlocksResumesAtBlock(t *testing.T) {
+	s := setupTestServer(t, 0)
+	s.AddBlock(1, 1, []byte("a"))
+	s.AddBlock(2, 2, []byte("b"))
+
+	c, err := New(s.Addr)
+	require.NoError(t, err)
+
+	err = c.RunBlocks(context.Background(), func(b *Block) error {
+		if b.Height == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), c.Next(), "next is the bookmark of the undelivered block")
+}
+
+func TestClientBlocksFramingError(t *testing.T) {
+	s := setupTestServer(t, 0)
+	s.AddBlock(1, 1, []byte("a"))
+	s.AddTxs([]byte("orphan"))
+
+	c, err := New(s.Addr)
+	require.NoError(t, err)
+
+	var heights []uint64
+	err = c.RunBlocks(context.Background(), func(b *Block) error {
+		heights = append(heights, b.Height)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrFraming)
+	var framing *FramingError
+	require.ErrorAs(t, err, &framing)
+	assert.Equal(t, uint64(4), framing.Entry)
+	assert.Equal(t, []uint64{1}, heights)
+}
+
+func TestClientFromHeight(t *testing.T) {
+	s := setupTestServer(t, 0)
+	s.AddBlock(1, 1, []byte("a"))
+	s.AddBlock(2, 2, []byte("b"))
+	s.AddBlock(4, 3, []byte("c"))
+
+	tests := []struct {
+		name   string
+		height uint64
+		want   uint64
+	}{
+		{name: "bookmarked height", height: 2, want: 2},
+		{name: "height without block", height: 3, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(s.Addr, WithFromHeight(tt.height), WithBackoff(10*time.Millisecond, 10*time.Millisecond))
+			require.NoError(t, err)
+
+			var got uint64
+			err = c.RunBlocks(context.Background(), func(b *Block) error {
+				got = b.Height
+				return ErrStop
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
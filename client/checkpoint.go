@@ -0,0 +1,84 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint persists the position of a stream consumer in a file, so a
+// restarted consumer continues with the entry after the last one it processed.
+type Checkpoint struct {
+	path string
+}
+
+type checkpointFile struct {
+	NextEntry uint64 `json:"next_entry"`
+}
+
+// NewCheckpoint returns a checkpoint stored at path.
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{path: path}
+}
+
+// Load returns the saved position. ok is false if nothing was saved yet.
+func (c *Checkpoint) Load() (next uint64, ok bool, err error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read checkpoint %s: %w", c.path, err)
+	}
+	var f checkpointFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return 0, false, fmt.Errorf("failed to parse checkpoint %s: %w", c.path, err)
+	}
+	return f.NextEntry, true, nil
+}
+
+// Save records next as the entry to continue from. The file is written and
+// synced before it replaces the previous one, and the directory is synced
+// after, so that a crash leaves either checkpoint behind, whole.
+func (c *Checkpoint) Save(next uint64) error {
+	data, err := json.Marshal(checkpointFile{NextEntry: next})
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(c.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes the entries of dir, such as a renamed file, durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync checkpoint directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoint directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// Package client reads the sequence from a dseq node's data stream, either
+// entry by entry with Run, or as whole blocks with RunBlocks and Blocks.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/cometbft/cometbft/libs/log"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ErrStop can be returned by an EntryFunc to stop streaming without consuming
+// the entry. Run then returns nil.
+var ErrStop = errors.New("stop streaming")
+
+// EntryFunc processes a stream entry. Entries are delivered in order, exactly
+// once, and the next one is only delivered after EntryFunc returns.
+type EntryFunc func(e *datastreamer.FileEntry) error
+
+// Client streams entries from a dseq node. It reconnects with exponential
+// backoff when the connection drops, and resumes after the last entry it
+// delivered.
+type Client struct {
+	addr       string
+	next       uint64
+	fromHeight uint64
+	bookmark   []byte // block bookmark to start from, until the first entry arrives
+	checkpoint *Checkpoint
+	saved      uint64 // position last saved to the checkpoint
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     log.Logger
+}
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// WithFromEntry sets the entry to start streaming from.
+func WithFromEntry(entry uint64) Option {
+	return func(c *Client) error {
+		c.next = entry
+		return nil
+	}
+}
+
+// WithFromHeight makes RunBlocks and Blocks start at the block at height. If the
+// node has a bookmark for that block, streaming starts there; otherwise the
+// blocks below height are read and skipped.
+func WithFromHeight(height uint64) Option {
+	return func(c *Client) error {
+		c.fromHeight = height
+		c.bookmark = app.BlockBookmark(height)
+		return nil
+	}
+}
+
+// WithCheckpoint saves the position of the client to path as soon as the
+// handler returns for an entry, or for a block with RunBlocks. If the file
+// already holds a position, streaming resumes from there, so a restarted
+// client delivers nothing twice: only a crash while the handler runs makes it
+// deliver that entry again.
+func WithCheckpoint(path string) Option {
+	return func(c *Client) error {
+		if path == "" {
+			return fmt.Errorf("checkpoint path cannot be empty")
+		}
+		c.checkpoint = NewCheckpoint(path)
+		return nil
+	}
+}
+
+// WithBackoff sets the delays between reconnection attempts.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("invalid backoff %s to %s", min, max)
+		}
+		c.minBackoff, c.maxBackoff = min, max
+		return nil
+	}
+}
+
+// WithLogger sets the logger reconnections are reported to.
+func WithLogger(logger log.Logger) Option {
+	return func(c *Client) error {
+		if logger == nil {
+			return fmt.Errorf("logger cannot be nil")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// New constructs a Client for the stream served at addr (host:port).
+func New(addr string, opts ...Option) (*Client, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("address cannot be empty")
+	}
+
+	c := &Client{
+		addr:       addr,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		logger:     log.NewNopLogger(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
+	if c.checkpoint != nil {
+		next, ok, err := c.checkpoint.Load()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.next = next
+			c.bookmark = nil
+		}
+		c.saved = next
+	}
+
+	return c, nil
+}
+
+// Next returns the number of the next entry the client will deliver.
+func (c *Client) Next() uint64 {
+	return c.next
+}
+
+// Header returns the header of the node's stream.
+func (c *Client) Header(ctx context.Context) (datastreamer.HeaderEntry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err := dial(ctx, c.addr)
+	if err != nil {
+		return datastreamer.HeaderEntry{}, err
+	}
+	return conn.header()
+}
+
+// Run delivers entries to fn until ctx is done, fn returns an error, or fn
+// returns ErrStop. Connection errors are retried; fn's errors are returned.
+func (c *Client) Run(ctx context.Context, fn EntryFunc) error {
+	return c.run(ctx, true, fn)
+}
+
+// run implements Run. The checkpoint is saved after each delivered entry if
+// saveEntries is set.
+func (c *Client) run(ctx context.Context, saveEntries bool, fn EntryFunc) error {
+	backoff := c.minBackoff
+	for {
+		delivered, err := c.stream(ctx, saveEntries, fn)
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var fnErr entryError
+		if errors.As(err, &fnErr) {
+			return fnErr.err
+		}
+
+		if delivered {
+			backoff = c.minBackoff
+		}
+		c.logger.Info("stream disconnected, reconnecting", "node", c.addr, "next-entry", c.next, "retry-in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// entryError wraps errors returned by the EntryFunc, which are not retried.
+type entryError struct {
+	err error
+}
+
+func (e entryError) Error() string {
+	return e.err.Error()
+}
+
+// stream runs a single connection. It reports whether any entry was delivered.
+func (c *Client) stream(ctx context.Context, saveEntries bool, fn EntryFunc) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err := dial(ctx, c.addr)
+	if err != nil {
+		return false, err
+	}
+	if c.bookmark != nil {
+		err = conn.startBookmark(c.bookmark)
+		var cmdErr *commandError
+		if errors.As(err, &cmdErr) && cmdErr.code == datastreamer.CmdErrBadFromBookmark {
+			// no bookmark for the height, the blocks below it are skipped instead
+			c.bookmark = nil
+		}
+	} else {
+		err = conn.start(c.next)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	delivered := false
+	for ctx.Err() == nil {
+		e, err := conn.entry()
+		if err != nil {
+			return delivered, err
+		}
+
+		if c.bookmark != nil {
+			c.next = e.Number
+			c.bookmark = nil
+		}
+
+		switch {
+		case e.Number < c.next:
+			// already delivered before a reconnection
+			continue
+		case e.Number > c.next:
+			return delivered, fmt.Errorf("entry %d received, expected entry %d", e.Number, c.next)
+		}
+
+		if err := fn(e); err != nil {
+			if errors.Is(err, ErrStop) {
+				return delivered, err
+			}
+			return delivered, entryError{err}
+		}
+		delivered = true
+		c.next = e.Number + 1
+
+		if saveEntries {
+			if err := c.saveCheckpoint(c.next); err != nil {
+				return delivered, entryError{err}
+			}
+		}
+	}
+	return delivered, ctx.Err()
+}
+
+// saveCheckpoint saves next as the position of the client, if it has a
+// checkpoint and the position moved since the last save.
+func (c *Client) saveCheckpoint(next uint64) error {
+	if c.checkpoint == nil || next == c.saved {
+		return nil
+	}
+	if err := c.checkpoint.Save(next); err != nil {
+		return err
+	}
+	c.saved = next
+	return nil
+}
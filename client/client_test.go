@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestServer starts a stream server holding n tx entries.
+func setupTestServer(t *testing.T, n int) *streamtest.Server {
+	t.Helper()
+	s := streamtest.NewServer(t)
+	if n > 0 {
+		s.AddTxs(testTxs(n)...)
+	}
+	return s
+}
+
+// testTxs returns n distinct one-byte txs.
+func testTxs(n int) [][]byte {
+	txs := make([][]byte, n)
+	for i := range txs {
+		txs[i] = []byte{byte(i)}
+	}
+	return txs
+}
+
+// collect runs c until it has delivered n entries and returns their numbers.
+func collect(t *testing.T, c *Client, n int) []uint64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got []uint64
+	err := c.Run(ctx, func(e *datastreamer.FileEntry) error {
+		got = append(got, e.Number)
+		if len(got) == n {
+			cancel()
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	return got
+}
+
+func TestClientRun(t *testing.T) {
+	addr := setupTestServer(t, 5).Addr
+
+	c, err := New(addr, WithFromEntry(2))
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint64{2, 3}, collect(t, c, 2))
+	assert.Equal(t, uint64(4), c.Next())
+}
+
+func TestClientCheckpoint(t *testing.T) {
+	addr := setupTestServer(t, 5).Addr
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := New(addr, WithCheckpoint(path))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2}, collect(t, c, 3))
+
+	// a new client resumes where the first stopped, ignoring the from entry
+	c, err = New(addr, WithCheckpoint(path), WithFromEntry(0))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4}, collect(t, c, 2))
+}
+
+func TestClientCheckpointSurvivesCrash(t *testing.T) {
+	if addr := os.Getenv("DSEQ_CRASH_ADDR"); addr != "" {
+		crashingReader(t, addr)
+		return
+	}
+
+	s := setupTestServer(t, 0)
+	s.AddBlock(1, 1, []byte("a"))
+	s.AddBlock(2, 2, []byte("b"))
+	dir := t.TempDir()
+	path, out := filepath.Join(dir, "checkpoint.json"), filepath.Join(dir, "out")
+
+	// the reader dies without returning in the middle of block 2, whose tx is entry 6
+	cmd := exec.Command(os.Args[0], "-test.run=^TestClientCheckpointSurvivesCrash$")
+	cmd.Env = append(os.Environ(), "DSEQ_CRASH_ADDR="+s.Addr, "DSEQ_CRASH_CHECKPOINT="+path, "DSEQ_CRASH_OUT="+out)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, crashExitCode, exitErr.ExitCode())
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var got []uint64
+	for _, line := range strings.Fields(string(data)) {
+		n, err := strconv.ParseUint(line, 10, 64)
+		require.NoError(t, err)
+		got = append(got, n)
+	}
+	require.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, got)
+
+	c, err := New(s.Addr, WithCheckpoint(path))
+	require.NoError(t, err)
+	got = append(got, collect(t, c, 2)...)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6, 7}, got, "every entry delivered once")
+}
+
+// crashExitCode is the exit code of crashingReader.
+const crashExitCode = 3
+
+// crashingReader appends the entries it reads from addr to a file and exits
+// the process, as a crash would, when it reaches entry 6.
+func crashingReader(t *testing.T, addr string) {
+	f, err := os.OpenFile(os.Getenv("DSEQ_CRASH_OUT"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	c, err := New(addr, WithCheckpoint(os.Getenv("DSEQ_CRASH_CHECKPOINT")))
+	require.NoError(t, err)
+	err = c.Run(context.Background(), func(e *datastreamer.FileEntry) error {
+		if e.Number == 6 {
+			os.Exit(crashExitCode)
+		}
+		_, err := fmt.Fprintln(f, e.Number)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestClientReconnects(t *testing.T) {
+	addr := setupTestServer(t, 6).Addr
+	proxy := newDroppingProxy(t, addr)
+
+	c, err := New(proxy.addr(), WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got []uint64
+	err = c.Run(ctx, func(e *datastreamer.FileEntry) error {
+		got = append(got, e.Number)
+		switch len(got) {
+		case 3:
+			proxy.drop()
+		case 6:
+			return ErrStop
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, got)
+}
+
+func TestClientErrorsAreReturned(t *testing.T) {
+	addr := setupTestServer(t, 3).Addr
+
+	c, err := New(addr)
+	require.NoError(t, err)
+
+	err = c.Run(context.Background(), func(e *datastreamer.FileEntry) error {
+		return io.ErrUnexpectedEOF
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, uint64(0), c.Next())
+}
+
+// droppingProxy forwards connections to a server and can drop them all.
+type droppingProxy struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newDroppingProxy(t *testing.T, target string) *droppingProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &droppingProxy{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			in, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", target)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, in, out)
+			p.mu.Unlock()
+			go io.Copy(in, out)
+			go io.Copy(out, in)
+		}
+	}()
+	return p
+}
+
+func (p *droppingProxy) addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *droppingProxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+)
+
+const (
+	headerSize = 38 // size of a header packet, see datastreamer.HeaderEntry
+)
+
+// conn speaks the datastreamer TCP protocol on a single connection.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// dial connects to a stream server. The connection is closed when ctx is done.
+func dial(ctx context.Context, addr string) (*conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+	return c, nil
+}
+
+// command sends cmd with its parameters and waits for the result.
+func (c *conn) command(cmd datastreamer.Command, params ...[]byte) error {
+	buf := binary.BigEndian.AppendUint64(nil, uint64(cmd))
+	buf = binary.BigEndian.AppendUint64(buf, app.StSequencer)
+	for _, p := range params {
+		buf = append(buf, p...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return err
+	}
+	return c.result(cmd)
+}
+
+// start asks the server to stream entries from entry onwards.
+func (c *conn) start(from uint64) error {
+	return c.command(datastreamer.CmdStart, binary.BigEndian.AppendUint64(nil, from))
+}
+
+// startBookmark asks the server to stream entries from the entry bookmark points at.
+func (c *conn) startBookmark(bookmark []byte) error {
+	param := binary.BigEndian.AppendUint32(nil, uint32(len(bookmark)))
+	return c.command(datastreamer.CmdStartBookmark, append(param, bookmark...))
+}
+
+// header returns the server's committed stream header.
+func (c *conn) header() (datastreamer.HeaderEntry, error) {
+	if err := c.command(datastreamer.CmdHeader); err != nil {
+		return datastreamer.HeaderEntry{}, err
+	}
+	if err := c.expect(datastreamer.PtHeader); err != nil {
+		return datastreamer.HeaderEntry{}, err
+	}
+	buf := make([]byte, headerSize)
+	buf[0] = datastreamer.PtHeader
+	if _, err := io.ReadFull(c.r, buf[1:]); err != nil {
+		return datastreamer.HeaderEntry{}, err
+	}
+	header, _ := decodeHeader(buf)
+	return header, nil
+}
+
+// decodeHeader decodes a header packet and returns the stream type it declares.
+func decodeHeader(buf []byte) (datastreamer.HeaderEntry, datastreamer.StreamType) {
+	return datastreamer.HeaderEntry{
+		Version:      buf[5],
+		SystemID:     binary.BigEndian.Uint64(buf[6:14]),
+		TotalLength:  binary.BigEndian.Uint64(buf[22:30]),
+		TotalEntries: binary.BigEndian.Uint64(buf[30:38]),
+	}, datastreamer.StreamType(binary.BigEndian.Uint64(buf[14:22]))
+}
+
+// result reads the result of cmd.
+func (c *conn) result(cmd datastreamer.Command) error {
+	if err := c.expect(datastreamer.PtResult); err != nil {
+		return err
+	}
+	fixed := make([]byte, datastreamer.FixedSizeResultEntry-1)
+	if _, err := io.ReadFull(c.r, fixed); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(fixed[0:4])
+	if length < datastreamer.FixedSizeResultEntry {
+		return fmt.Errorf("invalid result length %d", length)
+	}
+	msg := make([]byte, length-datastreamer.FixedSizeResultEntry)
+	if _, err := io.ReadFull(c.r, msg); err != nil {
+		return err
+	}
+	if code := binary.BigEndian.Uint32(fixed[4:8]); code != uint32(datastreamer.CmdErrOK) {
+		return &commandError{cmd: cmd, code: datastreamer.CommandError(code), msg: string(msg)}
+	}
+	return nil
+}
+
+// commandError is a command the server answered with an error.
+type commandError struct {
+	cmd  datastreamer.Command
+	code datastreamer.CommandError
+	msg  string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("command %s failed: %s (%d)", datastreamer.StrCommand[e.cmd], e.msg, e.code)
+}
+
+// entry reads the next streamed entry.
+func (c *conn) entry() (*datastreamer.FileEntry, error) {
+	if err := c.expect(datastreamer.PtData); err != nil {
+		return nil, err
+	}
+	return readEntry(c.r)
+}
+
+// readEntry reads a data entry whose packet type has already been read.
+func readEntry(r io.Reader) (*datastreamer.FileEntry, error) {
+	buf := make([]byte, datastreamer.FixedSizeFileEntry)
+	buf[0] = datastreamer.PtData
+	if _, err := io.ReadFull(r, buf[1:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(buf[1:5])
+	if length < datastreamer.FixedSizeFileEntry {
+		return nil, fmt.Errorf("invalid entry length %d", length)
+	}
+	buf = append(buf, make([]byte, length-datastreamer.FixedSizeFileEntry)...)
+	if _, err := io.ReadFull(r, buf[datastreamer.FixedSizeFileEntry:]); err != nil {
+		return nil, err
+	}
+	e, err := datastreamer.DecodeBinaryToFileEntry(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// expect reads the packet type and checks it is the wanted one.
+func (c *conn) expect(packet byte) error {
+	got, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if got != packet {
+		return fmt.Errorf("unexpected packet type %d, expected %d", got, packet)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+)
+
+// fileMagic opens every stream file, in front of the header packet.
+var fileMagic = []byte("polygonDATSTREAM")
+
+// File reads a stream file written by a datastreamer server directly, without a
+// server or network. Only the entries committed in the file header are read.
+type File struct {
+	f      *os.File
+	header datastreamer.HeaderEntry
+	size   int64
+}
+
+// OpenFile opens the stream file at path for reading.
+func OpenFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := readFileHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+func readFileHeader(f *os.File) (*File, error) {
+	buf := make([]byte, len(fileMagic)+headerSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return nil, fmt.Errorf("failed to read stream file header: %w", err)
+	}
+	if !bytes.Equal(buf[:len(fileMagic)], fileMagic) {
+		return nil, errors.New("not a stream file")
+	}
+	packet := buf[len(fileMagic):]
+	if packet[0] != datastreamer.PtHeader {
+		return nil, fmt.Errorf("invalid header packet type %d", packet[0])
+	}
+	header, streamType := decodeHeader(packet)
+	if streamType != app.StSequencer {
+		return nil, fmt.Errorf("unexpected stream type %d", streamType)
+	}
+	if header.TotalLength < datastreamer.PageHeaderSize {
+		return nil, fmt.Errorf("invalid stream length %d", header.TotalLength)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < int64(header.TotalLength) {
+		return nil, fmt.Errorf("file is %d bytes, header expects %d", info.Size(), header.TotalLength)
+	}
+	return &File{f: f, header: header, size: info.Size()}, nil
+}
+
+// Header returns the header of the stream file.
+func (f *File) Header() datastreamer.HeaderEntry {
+	return f.header
+}
+
+// Size returns the size of the file on disk, which includes preallocated pages.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Entries calls fn for every entry from entry number from onwards, in order,
+// until the last committed entry or until fn returns an error. Returning
+// ErrStop from fn ends the iteration without error.
+func (f *File) Entries(from uint64, fn EntryFunc) error {
+	if from >= f.header.TotalEntries {
+		return nil
+	}
+	pos, err := f.seek(from)
+	if err != nil {
+		return err
+	}
+
+	end := int64(f.header.TotalLength)
+	r := bufio.NewReaderSize(io.NewSectionReader(f.f, pos, end-pos), 64*1024)
+	for pos < end {
+		packet, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("failed to read entry at offset %d: %w", pos, err)
+		}
+		if packet == datastreamer.PtPadding {
+			// the rest of the page is padding, entries continue on the next one
+			pos = pageOffset(pageOf(pos) + 1)
+			r.Reset(io.NewSectionReader(f.f, pos, max(end-pos, 0)))
+			continue
+		}
+		if packet != datastreamer.PtData {
+			return fmt.Errorf("unexpected packet type %d at offset %d", packet, pos)
+		}
+
+		e, err := readEntry(r)
+		if err != nil {
+			return fmt.Errorf("failed to read entry at offset %d: %w", pos, err)
+		}
+		pos += int64(e.Length)
+
+		if e.Number < from {
+			continue
+		}
+		if err := fn(e); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+		if e.Number+1 >= f.header.TotalEntries {
+			return nil
+		}
+	}
+	return fmt.Errorf("stream file ends before entry %d", f.header.TotalEntries-1)
+}
+
+// Blocks calls fn for every complete block starting at or after entry from,
+// with the same stopping behavior as Entries.
+func (f *File) Blocks(from uint64, fn BlockFunc) error {
+	a := &assembler{delivered: from}
+	return f.Entries(from, func(e *datastreamer.FileEntry) error {
+		block, err := a.add(e)
+		if err != nil || block == nil {
+			return err
+		}
+		return fn(block)
+	})
+}
+
+// seek returns the offset of the data page holding entry num. Every data page
+// starts with an entry, so the pages are binary searched by their first entry.
+func (f *File) seek(num uint64) (int64, error) {
+	pages := pageOf(int64(f.header.TotalLength)-1) + 1
+
+	lo, hi := int64(0), pages-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		first, err := f.firstEntry(mid)
+		if err != nil {
+			return 0, err
+		}
+		if first <= num {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return pageOffset(lo), nil
+}
+
+// firstEntry returns the number of the first entry of a data page.
+func (f *File) firstEntry(page int64) (uint64, error) {
+	buf := make([]byte, datastreamer.FixedSizeFileEntry)
+	if _, err := f.f.ReadAt(buf, pageOffset(page)); err != nil {
+		return 0, fmt.Errorf("failed to read data page %d: %w", page, err)
+	}
+	if buf[0] != datastreamer.PtData {
+		return 0, fmt.Errorf("data page %d does not start with an entry", page)
+	}
+	return binary.BigEndian.Uint64(buf[9:17]), nil
+}
+
+// pageOf returns the data page holding the byte at offset pos.
+func pageOf(pos int64) int64 {
+	return (pos - datastreamer.PageHeaderSize) / datastreamer.PageDataSize
+}
+
+// pageOffset returns the file offset of a data page.
+func pageOffset(page int64) int64 {
+	return datastreamer.PageHeaderSize + page*datastreamer.PageDataSize
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestFile writes blocks of large txs, so that the file spans several
+// data pages, and returns the file path and the number of entries.
+func setupTestFile(t *testing.T, blocks int) (string, uint64) {
+	t.Helper()
+	s := streamtest.NewServer(t)
+	for h := 1; h <= blocks; h++ {
+		tx := bytes.Repeat([]byte{byte(h)}, 100*1024)
+		s.AddBlock(uint64(h), uint64(h), tx)
+	}
+	return s.Path, s.GetHeader().TotalEntries
+}
+
+func TestFileEntries(t *testing.T) {
+	path, total := setupTestFile(t, 30)
+
+	f, err := OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, total, f.Header().TotalEntries)
+	assert.Greater(t, f.Header().TotalLength, uint64(2*datastreamer.PageDataSize))
+
+	var got []uint64
+	require.NoError(t, f.Entries(0, func(e *datastreamer.FileEntry) error {
+		got = append(got, e.Number)
+		return nil
+	}))
+	require.Len(t, got, int(total))
+	for i, num := range got {
+		assert.Equal(t, uint64(i), num)
+	}
+
+	// entries on later pages are found without reading from the start
+	var first *datastreamer.FileEntry
+	require.NoError(t, f.Entries(total-2, func(e *datastreamer.FileEntry) error {
+		first = e
+		return ErrStop
+	}))
+	require.NotNil(t, first)
+	assert.Equal(t, total-2, first.Number)
+}
+
+func TestFileBlocks(t *testing.T) {
+	path, _ := setupTestFile(t, 30)
+
+	f, err := OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// starting inside block 1 skips to block 2
+	var heights []uint64
+	require.NoError(t, f.Blocks(2, func(b *Block) error {
+		heights = append(heights, b.Height)
+		require.Len(t, b.Txs, 1)
+		assert.Equal(t, byte(b.Height), b.Txs[0].Data[0])
+		return nil
+	}))
+	require.Len(t, heights, 29)
+	assert.Equal(t, uint64(2), heights[0])
+	assert.Equal(t, uint64(30), heights[28])
+}
+
+func TestOpenFileRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.bin")
+	require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte{1}, 4096), 0644))
+
+	_, err := OpenFile(path)
+	assert.ErrorContains(t, err, "not a stream file")
+}
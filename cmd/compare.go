@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+	cmtlog "github.com/cometbft/cometbft/libs/log"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/urfave/cli/v2"
+)
+
+// CompareStreams reads the streams of several nodes side by side and reports the
+// first entry where they differ. Without --follow it compares the entries every
+// node has and reports which nodes are behind; with --follow it keeps comparing
+// new entries as they are sequenced.
+func CompareStreams(cli *cli.Context) error {
+	var nodes []string
+	for _, node := range strings.Split(cli.String("nodes"), ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) < 2 {
+		return fmt.Errorf("at least two nodes are needed to compare")
+	}
+	from := cli.Uint64("from")
+	follow := cli.Bool("follow")
+	interval := cli.Duration("interval")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := cmtlog.NewTMLogger(cmtlog.NewSyncWriter(os.Stderr))
+	clients := make([]*client.Client, len(nodes))
+	for i, node := range nodes {
+		c, err := client.New(node, client.WithFromEntry(from), client.WithLogger(logger.With("node", node)))
+		if err != nil {
+			return fmt.Errorf("failed to create stream client: %w", err)
+		}
+		clients[i] = c
+	}
+
+	// a one-off run compares the entries every node already has
+	var to uint64
+	var totals []uint64
+	if !follow {
+		var err error
+		if totals, err = totalEntries(ctx, clients); err != nil {
+			return err
+		}
+		to = totals[0]
+		for _, total := range totals {
+			to = min(to, total)
+		}
+	}
+
+	cmp := &comparer{nodes: nodes, next: from}
+	streams := make([]chan *datastreamer.FileEntry, len(nodes))
+	errs := make([]error, len(nodes))
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for i := range clients {
+		streams[i] = make(chan *datastreamer.FileEntry, 1024)
+		if !follow && from >= to {
+			close(streams[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(streams[i])
+			errs[i] = clients[i].Run(ctx, func(e *datastreamer.FileEntry) error {
+				select {
+				case streams[i] <- e:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if !follow && e.Number+1 >= to {
+					return client.ErrStop
+				}
+				return nil
+			})
+		}(i)
+	}
+
+	if follow && interval > 0 {
+		go cmp.monitor(ctx, clients, interval)
+	}
+
+	err := cmp.run(ctx, streams)
+	if err == nil {
+		// a stream ended; find out why once every reader has stopped
+		cancel()
+		wg.Wait()
+		for i := range errs {
+			if errs[i] != nil && !errors.Is(errs[i], context.Canceled) {
+				err = fmt.Errorf("%s: %w", nodes[i], errs[i])
+				break
+			}
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(cmp.summary())
+	if totals != nil {
+		reportLag(nodes, totals)
+	}
+	return nil
+}
+
+// totalEntries returns the number of entries in the stream of each node.
+func totalEntries(ctx context.Context, clients []*client.Client) ([]uint64, error) {
+	totals := make([]uint64, len(clients))
+	for i, c := range clients {
+		header, err := c.Header(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream header: %w", err)
+		}
+		totals[i] = header.TotalEntries
+	}
+	return totals, nil
+}
+
+// reportLag prints how far each node is behind the most advanced one.
+func reportLag(nodes []string, totals []uint64) {
+	var head uint64
+	for _, total := range totals {
+		head = max(head, total)
+	}
+	for i, node := range nodes {
+		if totals[i] < head {
+			fmt.Printf("%s is behind by %d entries (%d of %d)\n", node, head-totals[i], totals[i], head)
+		} else {
+			fmt.Printf("%s is at the head (%d entries)\n", node, totals[i])
+		}
+	}
+}
+
+// comparer checks that the streams of several nodes hold the same entries.
+type comparer struct {
+	nodes []string
+
+	mu         sync.Mutex
+	next       uint64 // number of the next entry to compare
+	compared   uint64 // entries that matched on every node
+	height     uint64 // height of the block being compared, if inside one
+	lastHeight uint64 // height of the last block that matched on every node
+}
+
+// run compares entries from streams, one per node, until one of them ends.
+func (c *comparer) run(ctx context.Context, streams []chan *datastreamer.FileEntry) error {
+	entries := make([]*datastreamer.FileEntry, len(streams))
+	for {
+		for i, stream := range streams {
+			select {
+			case e, ok := <-stream:
+				if !ok {
+					return nil
+				}
+				entries[i] = e
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := c.compare(entries); err != nil {
+			return err
+		}
+	}
+}
+
+// compare checks that the same entry read from every node is identical.
+func (c *comparer) compare(entries []*datastreamer.FileEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	first := entries[0]
+	for i, e := range entries {
+		if e.Number != c.next {
+			return fmt.Errorf("%s sent entry %d, expected %d", c.nodes[i], e.Number, c.next)
+		}
+		if e.Type != first.Type || !bytes.Equal(e.Data, first.Data) {
+			return c.diverged(entries)
+		}
+	}
+
+	switch first.Type {
+	case app.EtBookmark:
+		if height, err := app.DecodeBookmark(first.Data); err == nil {
+			c.height = height
+		}
+	case app.EtL2BlockEnd:
+		if end, err := app.DecodeBlockEnd(first.Data); err == nil {
+			c.lastHeight = end.Height
+		}
+		c.height = 0
+	}
+	c.next++
+	c.compared++
+	return nil
+}
+
+// diverged describes the entry the nodes disagree on, grouping nodes that agree.
+func (c *comparer) diverged(entries []*datastreamer.FileEntry) error {
+	var b strings.Builder
+	if c.height > 0 {
+		fmt.Fprintf(&b, "nodes diverge at entry %d, in block %d", c.next, c.height)
+	} else {
+		fmt.Fprintf(&b, "nodes diverge at entry %d, after block %d", c.next, c.lastHeight)
+	}
+
+	grouped := make([]bool, len(entries))
+	for i, e := range entries {
+		if grouped[i] {
+			continue
+		}
+		var group []string
+		for j := i; j < len(entries); j++ {
+			if !grouped[j] && entries[j].Type == e.Type && bytes.Equal(entries[j].Data, e.Data) {
+				grouped[j] = true
+				group = append(group, c.nodes[j])
+			}
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", strings.Join(group, ", "), describeEntry(e))
+	}
+	return errors.New(b.String())
+}
+
+// summary reports how much of the streams matched.
+func (c *comparer) summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compared == 0 {
+		return "no entries to compare"
+	}
+	return fmt.Sprintf("%d entries match on all nodes, up to entry %d and block %d", c.compared, c.next-1, c.lastHeight)
+}
+
+// monitor periodically prints the comparison progress and how far behind each node is.
+func (c *comparer) monitor(ctx context.Context, clients []*client.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fmt.Println(c.summary())
+		totals, err := totalEntries(ctx, clients)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Error reading stream headers: %v\n", err)
+			}
+			continue
+		}
+		reportLag(c.nodes, totals)
+	}
+}
+
+// describeEntry summarizes an entry for divergence reports.
+func describeEntry(e *datastreamer.FileEntry) string {
+	d := decodeEntry(e)
+	switch {
+	case d.Bookmark != nil:
+		return fmt.Sprintf("bookmark of block %d", d.Bookmark.Height)
+	case d.BlockStart != nil:
+		return fmt.Sprintf("block_start height=%d hash=%s", d.BlockStart.Height, d.BlockStart.Hash)
+	case d.BlockEnd != nil:
+		return fmt.Sprintf("block_end height=%d txs=%d size=%d tx_root=%s", d.BlockEnd.Height, d.BlockEnd.NumTxs, d.BlockEnd.Size, d.BlockEnd.TxRoot)
+	case d.Tx != nil:
+		return fmt.Sprintf("tx hash=%s len=%d", d.Tx.Hash, len(d.Tx.Data))
+	default:
+		return fmt.Sprintf("%s %s", d.Type, hexutil.Encode(e.Data))
+	}
+}
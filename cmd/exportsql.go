@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/christophercampbell/dseq/client"
+	"github.com/christophercampbell/dseq/sqlexport"
+	cmtlog "github.com/cometbft/cometbft/libs/log"
+	"github.com/urfave/cli/v2"
+)
+
+// flushInterval bounds how long blocks exported from a live stream stay uncommitted.
+const flushInterval = time.Second
+
+// ExportSQL exports the sequence into a SQLite database, once from a stream file
+// or continuously from a node's stream. Both resume after the last exported block.
+func ExportSQL(cli *cli.Context) error {
+	file, node := cli.String("file"), cli.String("node")
+	if (file == "") == (node == "") {
+		return fmt.Errorf("exactly one of --file or --node is required")
+	}
+	batch := int(cli.Uint("batch"))
+	if batch <= 0 {
+		return fmt.Errorf("batch must be greater than 0")
+	}
+
+	db, err := sqlexport.Open(cli.String("db"))
+	if err != nil {
+		return err
+	}
+	e := &exporter{db: db, batch: batch, from: db.Next()}
+
+	if file != "" {
+		err = e.exportFile(file)
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = e.exportStream(ctx, node)
+	}
+
+	if closeErr := db.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	blocks, txs := db.Exported()
+	fmt.Printf("Exported %d blocks and %d txs from entry %d, next entry %d\n", blocks, txs, e.from, db.Next())
+	return err
+}
+
+// exporter adds blocks to the database, committing every batch blocks.
+type exporter struct {
+	db    *sqlexport.DB
+	batch int
+
+	from uint64
+}
+
+func (e *exporter) add(b *client.Block) error {
+	if err := e.db.Add(b); err != nil {
+		return err
+	}
+	if e.db.Pending() >= e.batch {
+		return e.db.Flush()
+	}
+	return nil
+}
+
+// exportFile exports the blocks of a stream file that are not exported yet.
+func (e *exporter) exportFile(path string) error {
+	f, err := client.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open stream file: %w", err)
+	}
+	defer f.Close()
+
+	if err := f.Blocks(e.db.Next(), e.add); err != nil {
+		return err
+	}
+	return e.db.Flush()
+}
+
+// exportStream exports blocks from a node's stream as they are sequenced, until
+// ctx is done.
+func (e *exporter) exportStream(ctx context.Context, node string) error {
+	stream, err := client.New(node,
+		client.WithFromEntry(e.db.Next()),
+		client.WithLogger(cmtlog.NewTMLogger(cmtlog.NewSyncWriter(os.Stderr))),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create stream client: %w", err)
+	}
+
+	blocks, errs := stream.Blocks(ctx)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case b, ok := <-blocks:
+			if !ok {
+				if err := e.db.Flush(); err != nil {
+					return err
+				}
+				return <-errs
+			}
+			if err := e.add(b); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if e.db.Pending() == 0 {
+				continue
+			}
+			if err := e.db.Flush(); err != nil {
+				return err
+			}
+			blocks, _ := e.db.Exported()
+			fmt.Printf("Exported %d blocks, next entry %d\n", blocks, e.db.Next())
+		}
+	}
+}
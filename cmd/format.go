@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// entryWriter renders stream entries in one of the `read` output formats.
+type entryWriter interface {
+	Write(e *datastreamer.FileEntry) error
+	Close() error
+}
+
+// newEntryWriter returns the writer for format: table, json, ndjson or raw.
+func newEntryWriter(format string, w io.Writer) (entryWriter, error) {
+	switch format {
+	case "table", "":
+		return &tableWriter{w: w}, nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "raw":
+		return &rawWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected table, json, ndjson or raw", format)
+	}
+}
+
+// entryKinds are the names of the known entry types.
+var entryKinds = map[datastreamer.EntryType]string{
+	app.EtBookmark:     "bookmark",
+	app.EtL2BlockStart: "block_start",
+	app.EtL2Tx:         "tx",
+	app.EtL2BlockEnd:   "block_end",
+}
+
+// entryKind returns a short description of an entry type.
+func entryKind(t datastreamer.EntryType) string {
+	if kind, ok := entryKinds[t]; ok {
+		return kind
+	}
+	return fmt.Sprintf("unknown_%d", t)
+}
+
+// tableKind returns the label of an entry type in the table format: its kind,
+// spelled with spaces.
+func tableKind(t datastreamer.EntryType) string {
+	return strings.ReplaceAll(entryKind(t), "_", " ")
+}
+
+// entryJSON is the decoded form of a stream entry.
+type entryJSON struct {
+	Entry      uint64          `json:"entry"`
+	Type       string          `json:"type"`
+	Bookmark   *bookmarkJSON   `json:"bookmark,omitempty"`
+	BlockStart *app.BlockStart `json:"block_start,omitempty"`
+	BlockEnd   *app.BlockEnd   `json:"block_end,omitempty"`
+	Tx         *txJSON         `json:"tx,omitempty"`
+	Data       hexutil.Bytes   `json:"data,omitempty"`  // set when the entry could not be decoded
+	Error      string          `json:"error,omitempty"` // why the entry could not be decoded
+}
+
+type bookmarkJSON struct {
+	Height uint64 `json:"height"`
+}
+
+type txJSON struct {
+	Hash     common.Hash   `json:"hash"`
+	Data     hexutil.Bytes `json:"data"`
+	Envelope *app.Envelope `json:"envelope,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// decodeEntry decodes the payload of an entry according to its type.
+func decodeEntry(e *datastreamer.FileEntry) *entryJSON {
+	out := &entryJSON{Entry: e.Number, Type: entryKind(e.Type)}
+
+	var err error
+	switch e.Type {
+	case app.EtBookmark:
+		var height uint64
+		if height, err = app.DecodeBookmark(e.Data); err == nil {
+			out.Bookmark = &bookmarkJSON{Height: height}
+		}
+	case app.EtL2BlockStart:
+		var start app.BlockStart
+		if start, err = app.DecodeBlockStart(e.Data); err == nil {
+			out.BlockStart = &start
+		}
+	case app.EtL2BlockEnd:
+		var end app.BlockEnd
+		if end, err = app.DecodeBlockEnd(e.Data); err == nil {
+			out.BlockEnd = &end
+		}
+	case app.EtL2Tx:
+		out.Tx = &txJSON{Hash: app.TxHash(e.Data), Data: e.Data}
+		if app.IsEnvelope(e.Data) {
+			if env, err := app.DecodeEnvelope(e.Data); err != nil {
+				out.Tx.Error = err.Error()
+			} else {
+				out.Tx.Envelope = env
+			}
+		}
+	default:
+		out.Data = e.Data
+	}
+
+	if err != nil {
+		out.Data = e.Data
+		out.Error = err.Error()
+	}
+	return out
+}
+
+// tableWriter prints one fixed-width line per entry with the data in hex.
+type tableWriter struct {
+	w io.Writer
+}
+
+func (t *tableWriter) Write(e *datastreamer.FileEntry) error {
+	_, err := fmt.Fprintf(t.w, "%6d | %11s | %s\n", e.Number, tableKind(e.Type), hexutil.Encode(e.Data))
+	return err
+}
+
+func (t *tableWriter) Close() error {
+	return nil
+}
+
+// jsonWriter prints a JSON array of decoded entries, closed by Close.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(e *datastreamer.FileEntry) error {
+	data, err := json.MarshalIndent(decodeEntry(e), "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.count == 0 {
+		sep = "[\n  "
+	}
+	j.count++
+	_, err = fmt.Fprintf(j.w, "%s%s", sep, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+// ndjsonWriter prints one decoded entry per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(e *datastreamer.FileEntry) error {
+	return n.enc.Encode(decodeEntry(e))
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// rawWriter writes the payload of every tx, each prefixed with its length as a
+// big-endian uint32. The payload of an envelope is the data it wraps, and a tx
+// that does not decode as one is its own payload. Block framing and bookmarks are
+// skipped.
+type rawWriter struct {
+	w io.Writer
+}
+
+func (r *rawWriter) Write(e *datastreamer.FileEntry) error {
+	if e.Type != app.EtL2Tx {
+		return nil
+	}
+	payload := e.Data
+	if env, err := app.DecodeEnvelope(e.Data); err == nil {
+		payload = env.Payload
+	}
+	buf := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	_, err := r.w.Write(append(buf, payload...))
+	return err
+}
+
+func (r *rawWriter) Close() error {
+	return nil
+}
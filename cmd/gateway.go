@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/christophercampbell/dseq/gateway"
+	cmtlog "github.com/cometbft/cometbft/libs/log"
+	"github.com/urfave/cli/v2"
+)
+
+// RunGateway serves a node's data stream over WebSocket and SSE.
+func RunGateway(cli *cli.Context) error {
+	logger := cmtlog.NewTMLogger(cmtlog.NewSyncWriter(os.Stdout))
+
+	srv, err := startGateway(cli.String("listen"), cli.String("node"), logger,
+		gateway.WithAllowedOrigins(cli.StringSlice("allowed-origin")...))
+	if err != nil {
+		return err
+	}
+	defer stopGateway(srv, logger)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+
+	return nil
+}
+
+// startGateway starts an HTTP gateway on listen for the stream served at node.
+func startGateway(listen, node string, logger cmtlog.Logger, opts ...gateway.Option) (*http.Server, error) {
+	logger = logger.With("module", "gateway")
+	srv, err := listenHTTP(listen, gateway.NewServer(node, logger, opts...).Handler())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start gateway: %w", err)
+	}
+	logger.Info("gateway listening", "addr", listen, "node", node)
+	return srv, nil
+}
+
+// listenHTTP serves handler on listen in the background. Listen errors, such as
+// the port being in use, are returned.
+func listenHTTP(listen string, handler http.Handler) (*http.Server, error) {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	return srv, nil
+}
+
+// stopGateway closes the gateway's listener and open subscriptions. Subscriptions
+// never finish by themselves, so there is nothing to drain gracefully.
+func stopGateway(srv *http.Server, logger cmtlog.Logger) {
+	if err := srv.Close(); err != nil {
+		logger.Error("failed to stop gateway", "error", err)
+	}
+}
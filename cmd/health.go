@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/app/health"
+	"github.com/cometbft/cometbft/config"
+	cmtlog "github.com/cometbft/cometbft/libs/log"
+	"github.com/cometbft/cometbft/node"
+)
+
+// startHealth serves the health of the node and its sequencer on listen. The
+// node is ready once it has caught up, its stream server answers, the app
+// state is at the stream's last block, and, if maxBlockAge is set, its last
+// block is recent.
+func startHealth(listen string, cfg *config.Config, n *node.Node, sequencer *app.SequencerApplication,
+	streamAddr string, maxBlockAge time.Duration, logger cmtlog.Logger) (*http.Server, error) {
+	checks := []health.Check{
+		health.CaughtUp(n.ConsensusReactor().WaitSync),
+		health.StreamServing(streamAddr),
+		health.StateMatchesStream(streamAddr, sequencer.Status),
+	}
+	if maxBlockAge > 0 {
+		lastBlock := func() (time.Time, bool) {
+			meta := n.BlockStore().LoadBlockMeta(n.BlockStore().Height())
+			if meta == nil {
+				return time.Time{}, false
+			}
+			return meta.Header.Time, true
+		}
+		idle := func() bool {
+			return !cfg.Consensus.CreateEmptyBlocks && n.Mempool().Size() == 0
+		}
+		checks = append(checks, health.RecentBlock(lastBlock, idle, maxBlockAge))
+	}
+
+	srv, err := listenHTTP(listen, health.NewServer(checks...).Handler())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start health server: %w", err)
+	}
+	logger.Info("serving health checks", "module", "health", "addr", listen)
+	return srv, nil
+}
+
+// stopHealth stops the health server, letting in-flight checks finish.
+func stopHealth(srv *http.Server, logger cmtlog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("failed to stop health server", "module", "health", "error", err)
+	}
+}
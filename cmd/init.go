@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/p2p"
+	"github.com/cometbft/cometbft/privval"
+	"github.com/cometbft/cometbft/types"
+	cmttime "github.com/cometbft/cometbft/types/time"
+	"github.com/urfave/cli/v2"
+)
+
+// InitNode creates the home directory of a single-validator node: the CometBFT
+// config with a dseq section, the node key, the validator key and state, and a
+// genesis with the dseq app state.
+func InitNode(cli *cli.Context) error {
+	home := cli.String("home")
+	cfg := newNodeConfig(home, cli.String("moniker"))
+
+	for _, path := range []string{configFile(cfg), cfg.GenesisFile(), cfg.PrivValidatorKeyFile(), cfg.NodeKeyFile()} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s is already initialized: %s exists", home, path)
+		}
+	}
+	pv, nodeKey, err := initKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if err := writeNodeConfig(cfg, app.DefaultConfig()); err != nil {
+		return err
+	}
+
+	pubKey, err := pv.GetPubKey()
+	if err != nil {
+		return fmt.Errorf("failed to get validator key: %w", err)
+	}
+	genesis, err := newGenesis(cli.String("chain-id"), []types.GenesisValidator{{
+		Address: pubKey.Address(),
+		PubKey:  pubKey,
+		Power:   10,
+		Name:    cfg.Moniker,
+	}})
+	if err != nil {
+		return err
+	}
+	if err := genesis.SaveAs(cfg.GenesisFile()); err != nil {
+		return fmt.Errorf("failed to save genesis: %w", err)
+	}
+
+	fmt.Printf("Initialized %s\n", home)
+	fmt.Printf("  Moniker:   %s\n", cfg.Moniker)
+	fmt.Printf("  Chain ID:  %s\n", genesis.ChainID)
+	fmt.Printf("  Node ID:   %s\n", nodeKey.ID())
+	fmt.Printf("  Validator: %s\n", pubKey.Address())
+	return nil
+}
+
+// newNodeConfig returns the CometBFT configuration of a new node in home, with
+// the defaults dseq changes: no empty blocks, as a sequencer has nothing to do
+// without txs.
+func newNodeConfig(home, moniker string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.SetRoot(home)
+	cfg.Moniker = moniker
+	cfg.Consensus.CreateEmptyBlocks = false
+	return cfg
+}
+
+// initKeys creates the config and data directories of a node and generates
+// its validator and node keys.
+func initKeys(cfg *config.Config) (*privval.FilePV, *p2p.NodeKey, error) {
+	for _, dir := range []string{filepath.Dir(cfg.GenesisFile()), filepath.Dir(cfg.PrivValidatorStateFile())} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	pv := privval.GenFilePV(cfg.PrivValidatorKeyFile(), cfg.PrivValidatorStateFile())
+	pv.Save()
+	nodeKey, err := p2p.LoadOrGenNodeKey(cfg.NodeKeyFile())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate node key: %w", err)
+	}
+	return pv, nodeKey, nil
+}
+
+// writeNodeConfig writes config.toml: the CometBFT configuration followed by
+// the dseq section.
+func writeNodeConfig(cfg *config.Config, dseq app.Config) error {
+	path := configFile(cfg)
+	config.WriteConfigFile(path, cfg)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open config: %w", err)
+	}
+	if err := dseq.WriteTOML(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// configFile returns the path of a node's config.toml.
+func configFile(cfg *config.Config) string {
+	return filepath.Join(cfg.RootDir, "config", "config.toml")
+}
+
+// newGenesis returns the genesis of a new chain with validators.
+func newGenesis(chainID string, validators []types.GenesisValidator) (*types.GenesisDoc, error) {
+	appState, err := json.Marshal(app.DefaultGenesisState())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode app state: %w", err)
+	}
+	genesis := &types.GenesisDoc{
+		ChainID:         chainID,
+		GenesisTime:     cmttime.Now(),
+		ConsensusParams: types.DefaultConsensusParams(),
+		Validators:      validators,
+		AppState:        appState,
+	}
+	if err := genesis.ValidateAndComplete(); err != nil {
+		return nil, fmt.Errorf("invalid genesis: %w", err)
+	}
+	return genesis, nil
+}
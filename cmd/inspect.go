@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+	"github.com/urfave/cli/v2"
+)
+
+// InspectFile summarizes a stream file, or dumps a range of its entries, by
+// reading the file directly. It works while the node owning the file is down.
+func InspectFile(cli *cli.Context) error {
+	if cli.NArg() != 1 {
+		return fmt.Errorf("expected the path of one stream file, got %d arguments", cli.NArg())
+	}
+	path := cli.Args().First()
+
+	f, err := client.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open stream file: %w", err)
+	}
+	defer f.Close()
+
+	if cli.Bool("dump") || cli.IsSet("entry") {
+		return dumpFile(cli, f)
+	}
+
+	stats, err := collectFileStats(f)
+	if err != nil {
+		return err
+	}
+	stats.print(path, f, cli.Bool("bookmarks"))
+	return nil
+}
+
+// dumpFile prints the entries of f selected by the bound and filter flags.
+func dumpFile(cli *cli.Context, f *client.File) error {
+	out, err := newEntryWriter(cli.String("format"), os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	sel, err := newSelection(cli)
+	if err != nil {
+		return err
+	}
+
+	from := cli.Uint64("from")
+	if cli.IsSet("entry") {
+		entry := cli.Uint64("entry")
+		if entry >= f.Header().TotalEntries {
+			return fmt.Errorf("entry %d is out of range, the file has %d entries", entry, f.Header().TotalEntries)
+		}
+		from, sel.to = entry, &entry
+	}
+
+	err = f.Entries(from, func(e *datastreamer.FileEntry) error {
+		show, last := sel.accept(e)
+		if show {
+			if err := out.Write(e); err != nil {
+				return err
+			}
+		}
+		if last {
+			return client.ErrStop
+		}
+		return nil
+	})
+
+	if closeErr := out.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// fileStats summarizes the entries of a stream file.
+type fileStats struct {
+	counts map[datastreamer.EntryType]uint64
+	bytes  map[datastreamer.EntryType]uint64
+
+	blocks                   uint64
+	firstHeight, lastHeight  uint64
+	firstTime, lastTime      time.Time
+	minBlockTxs, maxBlockTxs uint64
+
+	txs                  uint64
+	enveloped            uint64
+	minTxSize, maxTxSize uint64
+
+	bookmarks []bookmarkStat
+	invalid   uint64 // entries whose data could not be decoded
+}
+
+type bookmarkStat struct {
+	height uint64
+	entry  uint64
+}
+
+// collectFileStats reads every entry of f.
+func collectFileStats(f *client.File) (*fileStats, error) {
+	s := &fileStats{
+		counts: make(map[datastreamer.EntryType]uint64),
+		bytes:  make(map[datastreamer.EntryType]uint64),
+	}
+	err := f.Entries(0, func(e *datastreamer.FileEntry) error {
+		s.add(e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream file: %w", err)
+	}
+	return s, nil
+}
+
+func (s *fileStats) add(e *datastreamer.FileEntry) {
+	size := uint64(len(e.Data))
+	s.counts[e.Type]++
+	s.bytes[e.Type] += size
+
+	switch e.Type {
+	case app.EtBookmark:
+		height, err := app.DecodeBookmark(e.Data)
+		if err != nil {
+			s.invalid++
+			return
+		}
+		s.bookmarks = append(s.bookmarks, bookmarkStat{height: height, entry: e.Number})
+
+	case app.EtL2BlockStart:
+		start, err := app.DecodeBlockStart(e.Data)
+		if err != nil {
+			s.invalid++
+			return
+		}
+		if s.firstTime.IsZero() {
+			s.firstTime = start.Time
+		}
+		s.lastTime = start.Time
+
+	case app.EtL2Tx:
+		if s.txs == 0 || size < s.minTxSize {
+			s.minTxSize = size
+		}
+		s.maxTxSize = max(s.maxTxSize, size)
+		s.txs++
+		if app.IsEnvelope(e.Data) {
+			s.enveloped++
+		}
+
+	case app.EtL2BlockEnd:
+		end, err := app.DecodeBlockEnd(e.Data)
+		if err != nil {
+			s.invalid++
+			return
+		}
+		if s.blocks == 0 {
+			s.firstHeight = end.Height
+			s.minBlockTxs = end.NumTxs
+		}
+		s.lastHeight = end.Height
+		s.minBlockTxs = min(s.minBlockTxs, end.NumTxs)
+		s.maxBlockTxs = max(s.maxBlockTxs, end.NumTxs)
+		s.blocks++
+	}
+}
+
+func (s *fileStats) print(path string, f *client.File, listBookmarks bool) {
+	header := f.Header()
+	pages := (header.TotalLength - datastreamer.PageHeaderSize + datastreamer.PageDataSize - 1) / datastreamer.PageDataSize
+
+	fmt.Printf("File:        %s\n", path)
+	fmt.Printf("Size:        %d bytes on disk, %d used in %d data pages\n", f.Size(), header.TotalLength, pages)
+	fmt.Printf("Version:     %d\n", header.Version)
+	fmt.Printf("System ID:   %d\n", header.SystemID)
+	fmt.Printf("Entries:     %d\n", header.TotalEntries)
+
+	types := make([]datastreamer.EntryType, 0, len(s.counts))
+	for t := range s.counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, t := range types {
+		fmt.Printf("  %-11s %10d entries %12d bytes\n", entryKind(t), s.counts[t], s.bytes[t])
+	}
+	if s.invalid > 0 {
+		fmt.Printf("  %d entries could not be decoded\n", s.invalid)
+	}
+
+	if s.blocks == 0 {
+		fmt.Printf("Blocks:      none\n")
+	} else {
+		fmt.Printf("Blocks:      %d, heights %d to %d\n", s.blocks, s.firstHeight, s.lastHeight)
+		fmt.Printf("Time:        %s to %s\n", s.firstTime.Format(time.RFC3339), s.lastTime.Format(time.RFC3339))
+		fmt.Printf("Block txs:   min %d, avg %.1f, max %d\n", s.minBlockTxs, float64(s.txs)/float64(s.blocks), s.maxBlockTxs)
+	}
+	if s.txs > 0 {
+		fmt.Printf("Tx size:     min %d, avg %.1f, max %d bytes\n", s.minTxSize, float64(s.bytes[app.EtL2Tx])/float64(s.txs), s.maxTxSize)
+		fmt.Printf("Txs:         %d, %d enveloped\n", s.txs, s.enveloped)
+	}
+
+	if len(s.bookmarks) == 0 {
+		fmt.Printf("Bookmarks:   none\n")
+		return
+	}
+	first, last := s.bookmarks[0], s.bookmarks[len(s.bookmarks)-1]
+	fmt.Printf("Bookmarks:   %d, block %d at entry %d to block %d at entry %d\n", len(s.bookmarks), first.height, first.entry, last.height, last.entry)
+	if listBookmarks {
+		for _, b := range s.bookmarks {
+			fmt.Printf("  block %d at entry %d\n", b.height, b.entry)
+		}
+	}
+}
@@ -1,127 +1,326 @@
 package cmd
 
 import (
-	crand "crypto/rand"
+	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"io"
-	mrand "math/rand"
-	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/load"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/urfave/cli/v2"
 )
 
-// RunLoad executes a load test by sending concurrent requests to the specified nodes.
+// RunLoad executes a load test against the specified nodes, either a number of
+// concurrent requests or, with --rate or --stages, open-loop at target rates.
 func RunLoad(cli *cli.Context) error {
+	if path := cli.String("scenario"); path != "" {
+		return runScenario(cli, path)
+	}
+
 	nodesCsv := cli.String("nodes")
 	if nodesCsv == "" {
 		return fmt.Errorf("nodes parameter cannot be empty")
 	}
-	nodes := strings.Split(nodesCsv, ",")
+	nodes := splitCSV(nodesCsv)
 	if len(nodes) == 0 {
 		return fmt.Errorf("no valid nodes provided")
 	}
 
-	requests := int(cli.Uint("requests"))
-	if requests <= 0 {
-		return fmt.Errorf("requests must be greater than 0")
+	stages, err := loadStages(cli)
+	if err != nil {
+		return err
 	}
-
-	concurrency := int(cli.Uint("concurrency"))
-	if concurrency <= 0 {
-		return fmt.Errorf("concurrency must be greater than 0")
+	format, err := load.ParseFormat(cli.String("format"))
+	if err != nil {
+		return err
+	}
+	payloads, err := loadPayloads(cli, format)
+	if err != nil {
+		return err
+	}
+	mode, err := load.ParseMode(cli.String("mode"))
+	if err != nil {
+		return err
+	}
+	transport, err := load.ParseTransport(cli.String("transport"))
+	if err != nil {
+		return err
+	}
+	keys, err := loadKeys(cli)
+	if err != nil {
+		return err
 	}
 
-	// Create a channel to control concurrency
-	ch := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	wg.Add(requests)
+	out, err := newLoadOutput(cli)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	start := time.Now()
+	started := time.Now()
+	report, err := load.Run(ctx, load.Config{
+		Nodes:       nodes,
+		Requests:    int(cli.Uint("requests")),
+		Concurrency: int(cli.Uint("concurrency")),
+		Stages:      stages,
+		MaxInFlight: int(cli.Uint("max-in-flight")),
+		Streams:     splitCSV(cli.String("streams")),
+		StreamWait:  cli.Duration("stream-wait"),
+		Audit:       cli.Bool("audit"),
+		Payloads:    payloads,
+		Format:      format,
+		Namespaces:  splitCSV(cli.String("namespaces")),
+		Keys:        keys,
+		Producers:   int(cli.Uint("producers")),
+		Mode:        mode,
+		Transport:   transport,
+		Batch:       int(cli.Uint("batch")),
+	})
+	if err != nil {
+		return err
+	}
+	if err := report.Print(out.human); err != nil {
+		return err
+	}
+	if err := out.write(&load.Results{Version: buildVersion(cli), Started: started, Report: report}); err != nil {
+		return err
+	}
+	if report.Audit != nil && !report.Audit.Passed {
+		return fmt.Errorf("audit failed")
+	}
+	if report.InNonceOrder != nil && !*report.InNonceOrder {
+		return fmt.Errorf("producer txs out of nonce order")
+	}
+	return nil
+}
 
-	// Queue all requests
-	go func() {
-		for i := 0; i < requests; i++ {
-			ch <- struct{}{}
+// runScenario runs the phases of a scenario file and fails if any of them
+// misses its thresholds. Phases without nodes or streams of their own use
+// --nodes and --streams, and signed txs are from the --key or --keystore keys.
+func runScenario(cli *cli.Context, path string) error {
+	scenario, err := load.LoadScenario(path)
+	if err != nil {
+		return err
+	}
+	keys, err := loadKeys(cli)
+	if err != nil {
+		return err
+	}
+	nodes, streams := splitCSV(cli.String("nodes")), splitCSV(cli.String("streams"))
+	for i := range scenario.Phases {
+		cfg := &scenario.Phases[i].Config
+		if len(cfg.Nodes) == 0 {
+			cfg.Nodes = nodes
 		}
-	}()
-
-	var (
-		totalDuration time.Duration
-		durationMutex sync.Mutex
-	)
-
-	stop := make(chan struct{})
-	for i := 0; i < concurrency; i++ {
-		go func() {
-			for {
-				select {
-				case <-ch:
-					node := nodes[mrand.Intn(len(nodes))]
-					tx := makeTx(node)
-					duration := sendTx(tx)
-
-					durationMutex.Lock()
-					totalDuration += duration
-					durationMutex.Unlock()
-
-					wg.Done()
-				case <-stop:
-					return
-				}
-			}
-		}()
+		if len(cfg.Streams) == 0 {
+			cfg.Streams = streams
+		}
+		cfg.Keys = keys
 	}
 
-	wg.Wait()
-	close(stop)
+	out, err := newLoadOutput(cli)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	averageRequestTimeMs := float64(totalDuration.Milliseconds()) / float64(requests)
-	totalTime := time.Since(start)
+	started := time.Now()
+	report, err := load.RunScenario(ctx, scenario, func(p load.PhaseReport) {
+		if err := p.Print(out.human); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print phase report: %v\n", err)
+		}
+		fmt.Fprintln(out.human)
+	})
+	if err != nil {
+		return err
+	}
+	if err := report.Print(out.human); err != nil {
+		return err
+	}
+	if err := out.write(&load.Results{Version: buildVersion(cli), Started: started, Scenario: report}); err != nil {
+		return err
+	}
+	if !report.Passed {
+		return fmt.Errorf("scenario failed")
+	}
+	return nil
+}
 
-	fmt.Printf("Load test results:\n")
-	fmt.Printf("  Requests: %d\n", requests)
-	fmt.Printf("  Concurrency: %d\n", concurrency)
-	fmt.Printf("  Average request time: %.2f ms\n", averageRequestTimeMs)
-	fmt.Printf("  Total time: %s\n", totalTime)
+// RunLoadCompare compares the JSON results of two load runs and fails if the
+// second regressed from the first.
+func RunLoadCompare(cli *cli.Context) error {
+	if cli.NArg() != 2 {
+		return fmt.Errorf("expected two results files, old and new")
+	}
+	before, err := load.ReadResults(cli.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	after, err := load.ReadResults(cli.Args().Get(1))
+	if err != nil {
+		return err
+	}
 
+	c := load.Compare(before, after, load.Tolerances{
+		Relative:  cli.Float64("tolerance"),
+		ErrorRate: cli.Float64("error-tolerance"),
+	})
+	if err := c.Print(os.Stdout); err != nil {
+		return err
+	}
+	if c.Regressions > 0 {
+		return fmt.Errorf("%d regressions", c.Regressions)
+	}
 	return nil
 }
 
-// makeTx generates a random transaction and returns the URL to send it to.
-func makeTx(node string) string {
-	tx := make([]byte, 40)
-	if _, err := crand.Read(tx); err != nil {
-		// Fallback to math/rand if crypto/rand fails
-		mrand.Read(tx)
+// loadOutput is where the results of a load run go: the human-readable report
+// to stdout, and a machine-readable one, if --report is set, to --report-file.
+// Without a file the machine-readable report takes stdout, and the
+// human-readable one goes to stderr.
+type loadOutput struct {
+	human  io.Writer
+	format load.ReportFormat
+	file   string
+	report bool
+}
+
+func newLoadOutput(cli *cli.Context) (*loadOutput, error) {
+	out := &loadOutput{human: os.Stdout, file: cli.String("report-file")}
+	spec := cli.String("report")
+	if spec == "" {
+		if out.file != "" {
+			return nil, fmt.Errorf("--report-file needs --report")
+		}
+		return out, nil
+	}
+
+	var err error
+	if out.format, err = load.ParseReportFormat(spec); err != nil {
+		return nil, err
 	}
-	hexTx := common.BytesToHash(tx).Hex()
-	return fmt.Sprintf("http://%s/broadcast_tx_commit?tx=\"%s\"", node, hexTx)
+	out.report = true
+	if out.file == "" {
+		out.human = os.Stderr
+	}
+	return out, nil
 }
 
-// sendTx sends a transaction to the specified URL and returns the duration of the request.
-func sendTx(url string) time.Duration {
-	start := time.Now()
+// write writes the machine-readable report, if one was asked for.
+func (o *loadOutput) write(results *load.Results) error {
+	switch {
+	case !o.report:
+		return nil
+	case o.file != "":
+		return results.WriteFile(o.file, o.format)
+	default:
+		return results.Write(os.Stdout, o.format)
+	}
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		fmt.Printf("Error sending request to %s: %v\n", url, err)
-		return 0
+// buildVersion returns the version of this build, as set in the app's metadata
+// at link time, or as recorded by the Go toolchain.
+func buildVersion(cli *cli.Context) load.Version {
+	v := load.Version{Version: "dev", GitCommit: "unknown", AppVersion: app.AppVersion}
+	if s, ok := cli.App.Metadata["version"].(string); ok && s != "" {
+		v.Version = s
+	}
+	if s, ok := cli.App.Metadata["git_commit"].(string); ok && s != "" {
+		v.GitCommit = s
+	}
+	if v.GitCommit != "unknown" {
+		return v
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("Error closing response body: %v\n", err)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+				v.GitCommit = setting.Value[:7]
+			}
 		}
-	}()
+	}
+	return v
+}
 
-	// Drain response body
-	if _, err := io.ReadAll(resp.Body); err != nil {
-		fmt.Printf("Warning: error reading response body: %v\n", err)
+// loadKeys returns the producer keys given by --key or --keystore, if any.
+func loadKeys(cli *cli.Context) ([]*ecdsa.PrivateKey, error) {
+	hex, dir := cli.String("key"), cli.String("keystore")
+	switch {
+	case hex != "" && dir != "":
+		return nil, fmt.Errorf("only one of --key or --keystore can be used")
+	case hex != "":
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hex, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		return []*ecdsa.PrivateKey{key}, nil
+	case dir != "":
+		var password string
+		if path := cli.String("password-file"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read password file: %w", err)
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+		}
+		return load.LoadKeystore(dir, password)
 	}
+	return nil, nil
+}
 
-	fmt.Printf("URL: %s, Status Code: %d\n", url, resp.StatusCode)
-	return time.Since(start)
+// loadStages returns the open-loop stages given by --rate or --stages, or nil
+// for a closed-loop test.
+func loadStages(cli *cli.Context) ([]load.Stage, error) {
+	rate, spec := cli.Float64("rate"), cli.String("stages")
+	switch {
+	case rate > 0 && spec != "":
+		return nil, fmt.Errorf("only one of --rate or --stages can be used")
+	case spec != "":
+		return load.ParseStages(spec)
+	case rate > 0:
+		if cli.Duration("duration") <= 0 {
+			return nil, fmt.Errorf("--duration is required with --rate")
+		}
+		return load.RateStages(rate, cli.Duration("duration"), cli.Duration("ramp-up")), nil
+	case cli.IsSet("rate"):
+		return nil, fmt.Errorf("rate must be greater than 0")
+	}
+	return nil, nil
+}
+
+// loadPayloads returns the payloads replayed from --replay, or random ones
+// sized by --payload-size. Replays of raw txs don't start over at the end of
+// the file.
+func loadPayloads(cli *cli.Context, format load.Format) (load.Payloads, error) {
+	if path := cli.String("replay"); path != "" {
+		if cli.IsSet("payload-size") {
+			return nil, fmt.Errorf("only one of --replay or --payload-size can be used")
+		}
+		return load.ReplayPayloads(path, format != load.FormatRaw)
+	}
+	sizes, err := load.ParseSizes(cli.String("payload-size"))
+	if err != nil {
+		return nil, err
+	}
+	return load.RandomPayloads(sizes), nil
+}
+
+// splitCSV returns the non-empty values of a comma separated list.
+func splitCSV(csv string) []string {
+	var values []string
+	for _, v := range strings.Split(csv, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
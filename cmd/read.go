@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
-	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/christophercampbell/dseq/client"
+	cmtlog "github.com/cometbft/cometbft/libs/log"
 	"github.com/urfave/cli/v2"
 )
 
@@ -16,52 +19,69 @@ func ReadStream(cli *cli.Context) error {
 	node := cli.String("node")
 	from := cli.Uint64("from")
 
-	stream, err := datastreamer.NewClient(node, datastreamer.StreamType(1))
+	out, err := newEntryWriter(cli.String("format"), os.Stdout)
 	if err != nil {
-		return fmt.Errorf("failed to create stream client: %w", err)
+		return err
 	}
 
-	stream.FromEntry = from
-	stream.SetProcessEntryFunc(printEntryNum)
-
-	if err := stream.Start(); err != nil {
-		return fmt.Errorf("failed to start stream: %w", err)
+	sel, err := newSelection(cli)
+	if err != nil {
+		return err
 	}
 
-	if err := stream.ExecCommand(datastreamer.CmdStart); err != nil {
-		return fmt.Errorf("failed to execute start command: %w", err)
+	var v *verifier
+	if cli.Bool("verify") {
+		if v, err = newVerifier(cli.String("rpc")); err != nil {
+			return err
+		}
 	}
 
-	// Set up signal handling for graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	opts := []client.Option{
+		client.WithFromEntry(from),
+		client.WithLogger(cmtlog.NewTMLogger(cmtlog.NewSyncWriter(os.Stderr))),
+	}
+	if checkpoint := cli.String("checkpoint"); checkpoint != "" {
+		opts = append(opts, client.WithCheckpoint(checkpoint))
+	}
 
-	if err := stream.ExecCommand(datastreamer.CmdStop); err != nil {
-		return fmt.Errorf("failed to execute stop command: %w", err)
+	stream, err := client.New(node, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create stream client: %w", err)
 	}
 
-	return nil
-}
+	// Set up signal handling for graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-// printEntryNum prints information about a stream entry.
-func printEntryNum(e *datastreamer.FileEntry, c *datastreamer.StreamClient, _ *datastreamer.StreamServer) error {
-	if e == nil {
-		return fmt.Errorf("received nil entry")
+	err = stream.Run(ctx, func(e *datastreamer.FileEntry) error {
+		if sel.past(e) {
+			return client.ErrStop
+		}
+		if v != nil {
+			if err := v.check(e); err != nil {
+				return err
+			}
+		}
+		show, last := sel.accept(e)
+		if show {
+			if err := out.Write(e); err != nil {
+				return err
+			}
+		}
+		if last {
+			stop()
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		err = nil
 	}
 
-	kind := "unknown"
-	switch e.Type {
-	case 1:
-		kind = "block start"
-	case 2:
-		kind = "transaction"
-	case 3:
-		kind = "block end"
-	default:
-		kind = fmt.Sprintf("unknown type %d", e.Type)
+	if closeErr := out.Close(); closeErr != nil && err == nil {
+		err = closeErr
 	}
-
-	fmt.Printf("%6d | %11s | %s\n", e.Number, kind, hexutil.Encode(e.Data))
-	return nil
+	if v != nil {
+		fmt.Fprintln(os.Stderr, v.summary())
+	}
+	return err
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/urfave/cli/v2"
+)
+
+// RunRelay mirrors the stream of an upstream node into a local stream file and
+// serves it again, so readers don't have to connect to the consensus nodes.
+func RunRelay(cli *cli.Context) error {
+	homeDir := cli.String("home")
+	upstream := cli.String("upstream")
+	dataPort := uint16(cli.Uint("port"))
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create home directory: %w", err)
+	}
+	streamFile := strings.Join([]string{homeDir, "dseq.bin"}, "/")
+
+	relay, err := datastreamer.NewRelay(
+		upstream,
+		dataPort,
+		1,
+		1,
+		datastreamer.StreamType(app.StSequencer),
+		streamFile,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create relay: %w", err)
+	}
+
+	if err = relay.Start(); err != nil {
+		return fmt.Errorf("failed to start relay: %w", err)
+	}
+
+	fmt.Printf("Relaying %s on port %d\n", upstream, dataPort)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+
+	return nil
+}
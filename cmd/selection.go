@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+)
+
+// selection decides which entries `read` and `inspect` print and when they have read enough.
+type selection struct {
+	fromHeight uint64  // first block height to print
+	to         *uint64 // last entry number to read
+	toHeight   uint64  // last block height to read
+	count      uint64  // number of entries to print
+	types      map[datastreamer.EntryType]bool
+	namespace  string
+	producer   *common.Address
+
+	started bool // whether the block at fromHeight has been reached
+	printed uint64
+}
+
+// newSelection builds the selection from the bound and filter flags of `read` and `inspect`.
+func newSelection(cli *cli.Context) (*selection, error) {
+	s := &selection{
+		fromHeight: cli.Uint64("from-height"),
+		toHeight:   cli.Uint64("to-height"),
+		count:      cli.Uint64("count"),
+		namespace:  cli.String("namespace"),
+	}
+	if s.toHeight > 0 && s.toHeight < s.fromHeight {
+		return nil, fmt.Errorf("to-height (%d) is before from-height (%d)", s.toHeight, s.fromHeight)
+	}
+
+	if cli.IsSet("to") {
+		to := cli.Uint64("to")
+		if to < cli.Uint64("from") {
+			return nil, fmt.Errorf("to (%d) is before from (%d)", to, cli.Uint64("from"))
+		}
+		s.to = &to
+	}
+
+	for _, name := range cli.StringSlice("type") {
+		t, err := parseEntryKind(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if s.types == nil {
+			s.types = make(map[datastreamer.EntryType]bool)
+		}
+		s.types[t] = true
+	}
+
+	if producer := cli.String("producer"); producer != "" {
+		if !common.IsHexAddress(producer) {
+			return nil, fmt.Errorf("invalid producer address %q", producer)
+		}
+		addr := common.HexToAddress(producer)
+		s.producer = &addr
+	}
+
+	return s, nil
+}
+
+// parseEntryKind is the inverse of entryKind for the known entry types.
+func parseEntryKind(name string) (datastreamer.EntryType, error) {
+	for t, kind := range entryKinds {
+		if kind == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown entry type %q, expected bookmark, block_start, tx or block_end", name)
+}
+
+// past reports whether e lies beyond the requested range.
+func (s *selection) past(e *datastreamer.FileEntry) bool {
+	if s.to != nil && e.Number > *s.to {
+		return true
+	}
+	if s.toHeight > 0 {
+		if height, ok := blockHeight(e); ok && height > s.toHeight {
+			return true
+		}
+	}
+	return false
+}
+
+// accept reports whether e should be printed, and whether reading is done after it.
+func (s *selection) accept(e *datastreamer.FileEntry) (show bool, done bool) {
+	if s.past(e) {
+		return false, true
+	}
+
+	if s.fromHeight > 0 && !s.started {
+		if height, ok := blockHeight(e); !ok || height < s.fromHeight {
+			return false, s.to != nil && e.Number >= *s.to
+		}
+		s.started = true
+	}
+
+	if s.matches(e) {
+		show = true
+		s.printed++
+	}
+
+	switch {
+	case s.to != nil && e.Number >= *s.to:
+		done = true
+	case s.count > 0 && s.printed >= s.count:
+		done = true
+	case s.toHeight > 0 && e.Type == app.EtL2BlockEnd:
+		if end, err := app.DecodeBlockEnd(e.Data); err == nil && end.Height >= s.toHeight {
+			done = true
+		}
+	}
+	return show, done
+}
+
+// matches applies the type, namespace and producer filters. The namespace and
+// producer filters only apply to txs; opaque txs never match them.
+func (s *selection) matches(e *datastreamer.FileEntry) bool {
+	if s.types != nil && !s.types[e.Type] {
+		return false
+	}
+	if e.Type != app.EtL2Tx || (s.namespace == "" && s.producer == nil) {
+		return true
+	}
+	env, err := app.DecodeEnvelope(e.Data)
+	if err != nil {
+		return false
+	}
+	if s.namespace != "" && env.Namespace != s.namespace {
+		return false
+	}
+	if s.producer != nil && env.Producer != *s.producer {
+		return false
+	}
+	return true
+}
+
+// blockHeight returns the height of the block a bookmark or block start entry opens.
+func blockHeight(e *datastreamer.FileEntry) (uint64, bool) {
+	switch e.Type {
+	case app.EtBookmark:
+		height, err := app.DecodeBookmark(e.Data)
+		return height, err == nil
+	case app.EtL2BlockStart:
+		start, err := app.DecodeBlockStart(e.Data)
+		return start.Height, err == nil
+	}
+	return 0, false
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
 	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/gateway"
 	"github.com/cometbft/cometbft/config"
 	"github.com/cometbft/cometbft/libs/cli/flags"
 	cmtlog "github.com/cometbft/cometbft/libs/log"
@@ -16,20 +19,23 @@ import (
 	"github.com/cometbft/cometbft/p2p"
 	"github.com/cometbft/cometbft/privval"
 	"github.com/cometbft/cometbft/proxy"
+	"github.com/cometbft/cometbft/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/spf13/viper"
 	"github.com/urfave/cli/v2"
 )
 
+// streamClientsInterval is how often the clients of the stream server are counted.
+const streamClientsInterval = 5 * time.Second
+
 func StartNode(cli *cli.Context) error {
 	homeDir := cli.String("home")
-	dataPort := uint16(cli.Uint("port"))
 
 	cfg := config.DefaultConfig()
 	cfg.SetRoot(homeDir)
 
 	var err error
-	viper.SetConfigFile(fmt.Sprintf("%s/%s", homeDir, "config/config.toml"))
+	viper.SetConfigFile(configFile(cfg))
 	if err = viper.ReadInConfig(); err != nil {
 		return fmt.Errorf("failed to read config: %w", err)
 	}
@@ -40,6 +46,12 @@ func StartNode(cli *cli.Context) error {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
+	dseqCfg, err := loadDseqConfig(cli)
+	if err != nil {
+		return err
+	}
+	dataPort := dseqCfg.StreamPort
+
 	state, err := app.NewState(homeDir)
 	if err != nil {
 		return fmt.Errorf("failed to create state: %w", err)
@@ -85,12 +97,25 @@ func StartNode(cli *cli.Context) error {
 		return fmt.Errorf("failed to parse log level: %w", err)
 	}
 
+	genesis, err := node.DefaultGenesisDocProviderFunc(cfg)()
+	if err != nil {
+		return fmt.Errorf("failed to load genesis: %w", err)
+	}
+
+	// registered alongside CometBFT's metrics, which its Prometheus server serves
+	metrics, gatewayMetrics := app.NopMetrics(), gateway.NopMetrics()
+	if cfg.Instrumentation.Prometheus {
+		metrics = app.PrometheusMetrics(cfg.Instrumentation.Namespace, "chain_id", genesis.ChainID)
+		gatewayMetrics = gateway.PrometheusMetrics(cfg.Instrumentation.Namespace, "chain_id", genesis.ChainID)
+	}
+
 	sequencer, err := app.NewSequencer(
 		logger,
 		app.WithIdentity(cfg.Moniker),
 		app.WithAddress(addr),
 		app.WithState(state),
 		app.WithDataServer(streamServer),
+		app.WithMetrics(metrics),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create sequencer: %w", err)
@@ -102,7 +127,7 @@ func StartNode(cli *cli.Context) error {
 		pv,
 		nodeKey,
 		proxy.NewLocalClientCreator(sequencer),
-		node.DefaultGenesisDocProviderFunc(cfg),
+		func() (*types.GenesisDoc, error) { return genesis, nil },
 		config.DefaultDBProvider,
 		node.DefaultMetricsProvider(cfg.Instrumentation),
 		logger); err != nil {
@@ -120,9 +145,54 @@ func StartNode(cli *cli.Context) error {
 		n.Wait()
 	}()
 
+	if cfg.Instrumentation.Prometheus {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go app.WatchStreamClients(ctx, dataPort, streamClientsInterval, gatewayMetrics.StreamClients.With("transport", "tcp"), logger)
+	}
+
+	if listen := dseqCfg.Gateway; listen != "" {
+		srv, err := startGateway(listen, fmt.Sprintf("127.0.0.1:%d", dataPort), logger,
+			gateway.WithMetrics(gatewayMetrics), gateway.WithAllowedOrigins(dseqCfg.GatewayOrigins...))
+		if err != nil {
+			return err
+		}
+		defer stopGateway(srv, logger)
+	}
+
+	if listen := dseqCfg.Health; listen != "" {
+		srv, err := startHealth(listen, cfg, n, sequencer, fmt.Sprintf("127.0.0.1:%d", dataPort), dseqCfg.MaxBlockAge, logger)
+		if err != nil {
+			return err
+		}
+		defer stopHealth(srv, logger)
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
 	return nil
 }
+
+// loadDseqConfig returns the dseq section of the config viper has read, with
+// defaults for the settings it leaves out, overridden by the flags that are set.
+func loadDseqConfig(cli *cli.Context) (app.Config, error) {
+	cfg := app.DefaultConfig()
+	if err := viper.UnmarshalKey("dseq", &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to unmarshal dseq config: %w", err)
+	}
+	if cli.IsSet("port") {
+		cfg.StreamPort = uint16(cli.Uint("port"))
+	}
+	if cli.IsSet("gateway") {
+		cfg.Gateway = cli.String("gateway")
+	}
+	if cli.IsSet("health") {
+		cfg.Health = cli.String("health")
+	}
+	if err := cfg.ValidateBasic(); err != nil {
+		return cfg, fmt.Errorf("invalid dseq config: %w", err)
+	}
+	return cfg, nil
+}
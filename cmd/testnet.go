@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/p2p"
+	"github.com/cometbft/cometbft/types"
+	"github.com/urfave/cli/v2"
+)
+
+// portsPerNode is how far apart the CometBFT ports of consecutive testnet nodes are.
+const portsPerNode = 10
+
+// testnetNode is a node of a generated testnet.
+type testnetNode struct {
+	Name       string
+	Home       string
+	ID         p2p.ID
+	P2PPort    int
+	RPCPort    int
+	HealthPort int
+	StreamPort int
+	cfg        *config.Config
+}
+
+// GenerateTestnet creates the home directories of a network of validators on
+// one host, which share a genesis and peer with each other, and scripts to
+// start and stop them as local processes.
+func GenerateTestnet(cli *cli.Context) error {
+	n := cli.Int("validators")
+	if n < 1 {
+		return fmt.Errorf("validators must be at least 1")
+	}
+	out := cli.String("output")
+	if entries, err := os.ReadDir(out); err == nil && len(entries) > 0 {
+		return fmt.Errorf("output directory %s is not empty", out)
+	}
+	out, err := filepath.Abs(out)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	basePort, streamPort := cli.Int("base-port"), cli.Int("stream-port")
+	if err := checkTestnetPorts(n, basePort, streamPort); err != nil {
+		return err
+	}
+
+	nodes := make([]*testnetNode, n)
+	validators := make([]types.GenesisValidator, n)
+	for i := range nodes {
+		node := &testnetNode{
+			Name:       fmt.Sprintf("node%d", i),
+			P2PPort:    basePort + i*portsPerNode,
+			RPCPort:    basePort + i*portsPerNode + 1,
+			HealthPort: basePort + i*portsPerNode + 3,
+			StreamPort: streamPort + i,
+		}
+		node.Home = filepath.Join(out, node.Name)
+		node.cfg = newNodeConfig(node.Home, node.Name)
+
+		pv, nodeKey, err := initKeys(node.cfg)
+		if err != nil {
+			return err
+		}
+		node.ID = nodeKey.ID()
+		pubKey, err := pv.GetPubKey()
+		if err != nil {
+			return fmt.Errorf("failed to get validator key: %w", err)
+		}
+		validators[i] = types.GenesisValidator{Address: pubKey.Address(), PubKey: pubKey, Power: 10, Name: node.Name}
+		nodes[i] = node
+	}
+
+	genesis, err := newGenesis(cli.String("chain-id"), validators)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		var peers []string
+		for _, peer := range nodes {
+			if peer != node {
+				peers = append(peers, fmt.Sprintf("%s@127.0.0.1:%d", peer.ID, peer.P2PPort))
+			}
+		}
+
+		cfg := node.cfg
+		cfg.P2P.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", node.P2PPort)
+		cfg.P2P.PersistentPeers = strings.Join(peers, ",")
+		cfg.P2P.AddrBookStrict = false
+		cfg.P2P.AllowDuplicateIP = true
+		cfg.RPC.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", node.RPCPort)
+		cfg.Instrumentation.PrometheusListenAddr = fmt.Sprintf("127.0.0.1:%d", node.RPCPort+1)
+
+		dseq := app.DefaultConfig()
+		dseq.StreamPort = uint16(node.StreamPort)
+		dseq.Health = fmt.Sprintf("127.0.0.1:%d", node.HealthPort)
+		if err := writeNodeConfig(cfg, dseq); err != nil {
+			return err
+		}
+		if err := genesis.SaveAs(cfg.GenesisFile()); err != nil {
+			return fmt.Errorf("failed to save genesis: %w", err)
+		}
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the dseq binary: %w", err)
+	}
+	if err := writeLaunchers(out, binary, nodes); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated a %d-validator testnet, chain %s, in %s\n", n, genesis.ChainID, out)
+	for _, node := range nodes {
+		fmt.Printf("  %s  id %s  p2p 127.0.0.1:%d  rpc 127.0.0.1:%d  stream 127.0.0.1:%d  health 127.0.0.1:%d\n",
+			node.Name, node.ID, node.P2PPort, node.RPCPort, node.StreamPort, node.HealthPort)
+	}
+	fmt.Printf("Start it with %s and stop it with %s\n", filepath.Join(out, "start.sh"), filepath.Join(out, "stop.sh"))
+	return nil
+}
+
+// checkTestnetPorts fails unless the ports of n nodes are valid and the
+// CometBFT and health ports, from basePort, don't overlap the stream ports,
+// from streamPort.
+func checkTestnetPorts(n, basePort, streamPort int) error {
+	lastBase := basePort + (n-1)*portsPerNode + 3 // the health port of the last node
+	lastStream := streamPort + n - 1
+	if basePort < 1 || lastBase > 65535 {
+		return fmt.Errorf("ports %d to %d are not all valid, lower the base port or the validators", basePort, lastBase)
+	}
+	if streamPort < 1 || lastStream > 65535 {
+		return fmt.Errorf("stream ports %d to %d are not all valid, lower the stream port or the validators", streamPort, lastStream)
+	}
+	if streamPort <= lastBase && basePort <= lastStream {
+		return fmt.Errorf("stream ports %d to %d overlap ports %d to %d", streamPort, lastStream, basePort, lastBase)
+	}
+	return nil
+}
+
+var startScript = template.Must(template.New("start").Parse(`#!/usr/bin/env bash
+# Starts the nodes of this testnet as local processes, each logging to
+# dseq.log in its home. Ctrl-C, or stop.sh, stops them all.
+# Set DSEQ to run another dseq binary than the one that generated the testnet.
+set -u
+cd "$(dirname "$0")"
+DSEQ="${DSEQ:-{{ .Binary }}}"
+
+if [ -s pids ]; then
+	echo "testnet already running (see $(pwd)/pids); stop it with stop.sh" >&2
+	exit 1
+fi
+
+pids=()
+stop() {
+	kill "${pids[@]}" 2>/dev/null
+	wait
+	rm -f pids
+	exit 0
+}
+trap stop INT TERM
+
+{{ range .Nodes }}"$DSEQ" start --home {{ .Name }} >> {{ .Name }}/dseq.log 2>&1 &
+pids+=($!)
+echo "{{ .Name }}: pid $!, rpc http://127.0.0.1:{{ .RPCPort }}, stream 127.0.0.1:{{ .StreamPort }}, health http://127.0.0.1:{{ .HealthPort }}"
+{{ end }}
+echo "${pids[@]}" > pids
+wait
+rm -f pids
+`))
+
+var stopScript = `#!/usr/bin/env bash
+# Stops the nodes started by start.sh.
+cd "$(dirname "$0")"
+if [ ! -s pids ]; then
+	echo "testnet is not running" >&2
+	exit 1
+fi
+kill $(cat pids)
+`
+
+// writeLaunchers writes start.sh and stop.sh for the testnet in dir.
+func writeLaunchers(dir, binary string, nodes []*testnetNode) error {
+	var start strings.Builder
+	if err := startScript.Execute(&start, struct {
+		Binary string
+		Nodes  []*testnetNode
+	}{binary, nodes}); err != nil {
+		return fmt.Errorf("failed to write start script: %w", err)
+	}
+	for name, script := range map[string]string{"start.sh": start.String(), "stop.sh": stopScript} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
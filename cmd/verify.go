@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/christophercampbell/dseq/app"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// verifier checks the framing of the stream entries it is fed, recomputes each
+// block's tx root and size, and compares the resulting app hash, and each
+// block's hash, with the ones the node's CometBFT RPC reports.
+//
+// The app hash only encodes the number of txs sequenced, so matching app hashes
+// show the stream has the blocks and tx counts the chain committed to, not the
+// tx content. The content is only checked against each block end's tx root,
+// which is written to the stream by the same node.
+type verifier struct {
+	rpc *rpchttp.HTTP
+
+	synced     bool            // whether the first block boundary has been seen
+	next       *uint64         // expected next entry number
+	bookmark   *uint64         // height of the bookmark before the next block start
+	unmarked   bool            // whether the next block start may lack its bookmark, read before the first entry
+	block      *app.BlockStart // block being read, nil between blocks
+	txs        [][]byte
+	size       *uint64 // txs sequenced up to the last block
+	lastHeight uint64
+
+	blocks     uint64
+	firstBlock uint64
+}
+
+// newVerifier returns a verifier that checks app hashes against the RPC at rpcAddr.
+func newVerifier(rpcAddr string) (*verifier, error) {
+	if rpcAddr == "" {
+		return nil, fmt.Errorf("--verify needs --rpc to compare app hashes with the node's")
+	}
+	client, err := rpchttp.New(rpcAddr, "/websocket")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rpc client: %w", err)
+	}
+	return &verifier{rpc: client}, nil
+}
+
+// check verifies the next entry of the stream.
+func (v *verifier) check(e *datastreamer.FileEntry) error {
+	if v.next != nil && e.Number != *v.next {
+		return fmt.Errorf("entry %d received, expected entry %d", e.Number, *v.next)
+	}
+	next := e.Number + 1
+	v.next = &next
+
+	if !v.synced {
+		// reading may start in the middle of a block, skip to the next one
+		if e.Type != app.EtBookmark && e.Type != app.EtL2BlockStart {
+			return nil
+		}
+		v.synced = true
+		v.unmarked = e.Type == app.EtL2BlockStart
+	}
+
+	switch e.Type {
+	case app.EtBookmark:
+		if v.block != nil {
+			return v.diverged(v.block.Height, "bookmark at entry %d inside the block", e.Number)
+		}
+		height, err := app.DecodeBookmark(e.Data)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", e.Number, err)
+		}
+		if v.bookmark != nil {
+			return v.diverged(height, "bookmark at entry %d follows the bookmark for height %d", e.Number, *v.bookmark)
+		}
+		if height <= v.lastHeight {
+			return v.diverged(height, "bookmark at entry %d does not follow height %d", e.Number, v.lastHeight)
+		}
+		v.bookmark = &height
+
+	case app.EtL2BlockStart:
+		start, err := app.DecodeBlockStart(e.Data)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", e.Number, err)
+		}
+		if v.block != nil {
+			return v.diverged(v.block.Height, "missing block end before entry %d", e.Number)
+		}
+		if start.Height <= v.lastHeight {
+			return v.diverged(start.Height, "block at entry %d does not follow height %d", e.Number, v.lastHeight)
+		}
+		switch {
+		case v.bookmark != nil && *v.bookmark != start.Height:
+			return v.diverged(start.Height, "block at entry %d follows the bookmark for height %d", e.Number, *v.bookmark)
+		case v.bookmark == nil && !v.unmarked:
+			return v.diverged(start.Height, "block at entry %d has no bookmark", e.Number)
+		}
+		v.bookmark, v.unmarked = nil, false
+		v.block = &start
+		v.txs = v.txs[:0]
+
+	case app.EtL2Tx:
+		if v.block == nil {
+			return v.diverged(v.lastHeight, "orphan tx at entry %d after block end", e.Number)
+		}
+		v.txs = append(v.txs, e.Data)
+
+	case app.EtL2BlockEnd:
+		end, err := app.DecodeBlockEnd(e.Data)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", e.Number, err)
+		}
+		if v.block == nil {
+			return v.diverged(end.Height, "block end at entry %d without block start", e.Number)
+		}
+		if err := v.checkBlock(end); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("entry %d has unknown type %d", e.Number, e.Type)
+	}
+	return nil
+}
+
+// checkBlock verifies the block that end closes.
+func (v *verifier) checkBlock(end app.BlockEnd) error {
+	height := v.block.Height
+	if end.Height != height {
+		return v.diverged(height, "block end is for height %d", end.Height)
+	}
+	if end.NumTxs != uint64(len(v.txs)) {
+		return v.diverged(height, "block end counts %d txs, stream has %d", end.NumTxs, len(v.txs))
+	}
+	if root := app.TxRoot(v.txs); root != end.TxRoot {
+		return v.diverged(height, "tx root %s, block end has %s", root, end.TxRoot)
+	}
+	if v.size == nil {
+		// reading from the middle of the stream, trust the first block's count
+		size := end.Size - end.NumTxs
+		v.size = &size
+	}
+	if *v.size+end.NumTxs != end.Size {
+		return v.diverged(height, "size %d, block end has %d", *v.size+end.NumTxs, end.Size)
+	}
+	size := end.Size
+	v.size = &size
+
+	appHash, blockHash, err := v.committed(int64(height))
+	if err != nil {
+		return fmt.Errorf("failed to get hashes of height %d: %w", height, err)
+	}
+	if !bytes.Equal(v.block.Hash[:], blockHash) {
+		return v.diverged(height, "block hash %s, node has %s", v.block.Hash, hexutil.Encode(blockHash))
+	}
+	if got := app.AppHash(int64(end.Size)); !bytes.Equal(got, appHash) {
+		return v.diverged(height, "app hash %s, node has %s", hexutil.Encode(got), hexutil.Encode(appHash))
+	}
+
+	if v.blocks == 0 {
+		v.firstBlock = height
+	}
+	v.blocks++
+	v.lastHeight = height
+	v.block = nil
+	return nil
+}
+
+// committed returns the app hash the node committed after the block at
+// height, and the hash of that block. Both are in the header of the next
+// block, or, if height is the node's latest block, in the ABCI info and the
+// block itself.
+func (v *verifier) committed(height int64) (appHash, blockHash []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	next := height + 1
+	block, blockErr := v.rpc.Block(ctx, &next)
+	if blockErr == nil {
+		return block.Block.Header.AppHash, block.Block.Header.LastBlockID.Hash, nil
+	}
+
+	info, err := v.rpc.ABCIInfo(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case info.Response.LastBlockHeight < height:
+		return nil, nil, fmt.Errorf("node is at height %d", info.Response.LastBlockHeight)
+	case info.Response.LastBlockHeight > height:
+		return nil, nil, blockErr
+	}
+	last, err := v.rpc.Block(ctx, &height)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info.Response.LastBlockAppHash, last.BlockID.Hash, nil
+}
+
+// diverged builds the error reporting the first height at which the stream is wrong.
+func (v *verifier) diverged(height uint64, format string, args ...interface{}) error {
+	return fmt.Errorf("stream diverges at height %d: %s", height, fmt.Sprintf(format, args...))
+}
+
+// summary describes what has been verified so far.
+func (v *verifier) summary() string {
+	if v.blocks == 0 {
+		return "verified 0 blocks"
+	}
+	return fmt.Sprintf("verified %d blocks, heights %d to %d", v.blocks, v.firstBlock, v.lastHeight)
+}
@@ -0,0 +1,331 @@
+// Package gateway serves the finalized sequence of a dseq node over WebSocket
+// and Server-Sent Events, for clients that can't speak the datastreamer protocol.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christophercampbell/dseq/client"
+	"github.com/cometbft/cometbft/libs/log"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	pingInterval = 15 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// Server streams the blocks of a node's data stream to HTTP clients.
+//
+//	GET /ws   WebSocket, one JSON message per block
+//	GET /sse  Server-Sent Events, one "block" event per block
+//
+// Both accept the query parameters from_entry or from_height to resume from,
+// and namespace (repeated or comma separated) to only receive the txs of those
+// namespaces. SSE clients reconnecting with Last-Event-ID resume after the last
+// block they received.
+//
+// Browsers may only open streams from pages served by the gateway's own host,
+// unless WithAllowedOrigins allows others. Every stream reads the node's data
+// stream over a connection of its own, so each gateway client also costs the
+// node a stream client.
+type Server struct {
+	node     string
+	logger   log.Logger
+	upgrader websocket.Upgrader
+	metrics  *Metrics
+	origins  map[string]bool // allowed cross-origin pages, "*" for any
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMetrics sets the metrics the gateway records, NopMetrics by default.
+func WithMetrics(metrics *Metrics) Option {
+	return func(s *Server) {
+		if metrics != nil {
+			s.metrics = metrics
+		}
+	}
+}
+
+// WithAllowedOrigins lets pages of origins, such as "https://app.example.com",
+// open streams. "*" allows pages of any origin.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(s *Server) {
+		for _, origin := range origins {
+			if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+				s.origins[strings.ToLower(origin)] = true
+			}
+		}
+	}
+}
+
+// NewServer returns a gateway for the stream served at node (host:port).
+func NewServer(node string, logger log.Logger, opts ...Option) *Server {
+	s := &Server{
+		node:    node,
+		logger:  logger,
+		metrics: NopMetrics(),
+		origins: make(map[string]bool),
+	}
+	s.upgrader.CheckOrigin = s.allowOrigin
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// allowOrigin reports whether the page that sent r may open a stream. Requests
+// without an Origin header do not come from browsers, and are allowed.
+func (s *Server) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.origins["*"] || s.origins[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// connected counts a client of transport until the returned func is called.
+func (s *Server) connected(transport string) func() {
+	clients := s.metrics.StreamClients.With("transport", transport)
+	clients.Add(1)
+	return func() { clients.Add(-1) }
+}
+
+// Handler returns the HTTP handler of the gateway.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.serveWebSocket)
+	mux.HandleFunc("/sse", s.serveSSE)
+	return mux
+}
+
+// message is the JSON form of a block sent to clients. NextEntry is the
+// from_entry to resume after this block.
+type message struct {
+	Type      string        `json:"type"`
+	NextEntry uint64        `json:"next_entry"`
+	Block     *client.Block `json:"block"`
+}
+
+func newMessage(b *client.Block) message {
+	return message{Type: "block", NextEntry: b.LastEntry + 1, Block: b}
+}
+
+// subscription is what a client asked to receive.
+type subscription struct {
+	fromEntry  uint64
+	fromHeight uint64
+	namespaces map[string]bool
+}
+
+// parseSubscription reads the subscription from the request's query parameters.
+func parseSubscription(r *http.Request) (*subscription, error) {
+	q := r.URL.Query()
+	sub := &subscription{}
+
+	var err error
+	if v := q.Get("from_entry"); v != "" {
+		if sub.fromEntry, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid from_entry %q", v)
+		}
+	}
+	if v := q.Get("from_height"); v != "" {
+		if q.Has("from_entry") {
+			return nil, fmt.Errorf("from_entry and from_height are exclusive")
+		}
+		if sub.fromHeight, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid from_height %q", v)
+		}
+	}
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if sub.fromEntry, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid Last-Event-ID %q", v)
+		}
+		sub.fromHeight = 0
+	}
+
+	for _, v := range q["namespace"] {
+		for _, ns := range strings.Split(v, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				if sub.namespaces == nil {
+					sub.namespaces = make(map[string]bool)
+				}
+				sub.namespaces[ns] = true
+			}
+		}
+	}
+	return sub, nil
+}
+
+// filter returns the block with only the txs of the subscribed namespaces, or
+// nil if none of its txs match.
+func (sub *subscription) filter(b *client.Block) *client.Block {
+	if sub.namespaces == nil {
+		return b
+	}
+	filtered := *b
+	filtered.Txs = make([]client.Tx, 0, len(b.Txs))
+	for _, tx := range b.Txs {
+		if tx.Envelope != nil && sub.namespaces[tx.Envelope.Namespace] {
+			filtered.Txs = append(filtered.Txs, tx)
+		}
+	}
+	if len(filtered.Txs) == 0 {
+		return nil
+	}
+	return &filtered
+}
+
+// stream reads the blocks of the subscription from the node and passes them to send.
+func (s *Server) stream(ctx context.Context, sub *subscription, send func(message) error) error {
+	opts := []client.Option{
+		client.WithFromEntry(sub.fromEntry),
+		client.WithLogger(s.logger),
+	}
+	if sub.fromHeight > 0 {
+		opts = append(opts, client.WithFromHeight(sub.fromHeight))
+	}
+	c, err := client.New(s.node, opts...)
+	if err != nil {
+		return err
+	}
+	return c.RunBlocks(ctx, func(b *client.Block) error {
+		if b = sub.filter(b); b == nil {
+			return nil
+		}
+		return send(newMessage(b))
+	})
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub, err := parseSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Error("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	defer s.connected("websocket")()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// the client sends nothing; reading detects when it goes away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	write := func(messageType int, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteMessage(messageType, data)
+	}
+	go keepAlive(ctx, func() error { return write(websocket.PingMessage, nil) })
+
+	err = s.stream(ctx, sub, func(m message) error {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return write(websocket.TextMessage, data)
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error("websocket stream failed", "error", err)
+		_ = write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+	}
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	sub, err := parseSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.allowOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	defer s.connected("sse")()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var mu sync.Mutex
+	write := func(format string, args ...interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	go keepAlive(ctx, func() error { return write(": ping\n\n") })
+
+	err = s.stream(ctx, sub, func(m message) error {
+		data, err := json.Marshal(m.Block)
+		if err != nil {
+			return err
+		}
+		return write("id: %d\nevent: block\ndata: %s\n\n", m.NextEntry, data)
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error("sse stream failed", "error", err)
+		_ = write("event: error\ndata: %q\n\n", err.Error())
+	}
+}
+
+// keepAlive calls ping periodically until ctx is done or ping fails.
+func keepAlive(ctx context.Context, ping func() error) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
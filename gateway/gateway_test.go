@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/christophercampbell/dseq/client"
+	"github.com/cometbft/cometbft/libs/log"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestGateway serves a stream of three blocks through a gateway.
+// Block 1 has a tx in namespace "a", block 2 in "b" and block 3 in both.
+func setupTestGateway(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+	ds := streamtest.NewServer(t)
+	ds.AddBlock(1, 1, envelope(t, "a"))
+	ds.AddBlock(2, 2, envelope(t, "b"))
+	ds.AddBlock(3, 4, envelope(t, "a"), envelope(t, "b"))
+
+	srv := httptest.NewServer(NewServer(ds.Addr, log.NewNopLogger(), opts...).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func envelope(t *testing.T, namespace string) []byte {
+	t.Helper()
+	tx, err := (&app.Envelope{Namespace: namespace, Payload: []byte("payload")}).Encode()
+	require.NoError(t, err)
+	return tx
+}
+
+type event struct {
+	id    string
+	block client.Block
+}
+
+// readEvents reads n events from an SSE endpoint.
+func readEvents(t *testing.T, url, lastEventID string, n int) []event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []event
+	var ev event
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.block))
+		case line == "":
+			events = append(events, ev)
+			ev = event{}
+		}
+	}
+	require.Len(t, events, n)
+	return events
+}
+
+func TestGatewaySSE(t *testing.T) {
+	srv := setupTestGateway(t)
+
+	events := readEvents(t, srv.URL+"/sse", "", 3)
+	assert.Equal(t, uint64(1), events[0].block.Height)
+	assert.Equal(t, "4", events[0].id)
+	assert.Equal(t, uint64(3), events[2].block.Height)
+	assert.Len(t, events[2].block.Txs, 2)
+
+	// reconnecting with the last event id resumes after that block
+	events = readEvents(t, srv.URL+"/sse", events[0].id, 1)
+	assert.Equal(t, uint64(2), events[0].block.Height)
+}
+
+func TestGatewaySSENamespaces(t *testing.T) {
+	srv := setupTestGateway(t)
+
+	events := readEvents(t, srv.URL+"/sse?namespace=b", "", 2)
+	assert.Equal(t, uint64(2), events[0].block.Height)
+	assert.Equal(t, uint64(3), events[1].block.Height)
+	require.Len(t, events[1].block.Txs, 1)
+	assert.Equal(t, "b", events[1].block.Txs[0].Envelope.Namespace)
+}
+
+func TestGatewayWebSocket(t *testing.T) {
+	srv := setupTestGateway(t)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?from_height=2&namespace=a,b"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+
+	var m message
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, "block", m.Type)
+	assert.Equal(t, uint64(2), m.Block.Height)
+	assert.Equal(t, m.Block.LastEntry+1, m.NextEntry)
+
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, uint64(3), m.Block.Height)
+}
+
+func TestGatewayBadRequest(t *testing.T) {
+	srv := setupTestGateway(t)
+
+	for _, query := range []string{"from_entry=x", "from_height=-1", "from_entry=1&from_height=1"} {
+		resp, err := http.Get(srv.URL + "/sse?" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestGatewayMetrics(t *testing.T) {
+	srv := setupTestGateway(t, WithMetrics(PrometheusMetrics("gateway_test")))
+	clients := func(transport string) float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		for _, f := range families {
+			if f.GetName() != "gateway_test_dseq_stream_clients" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				if m.GetLabel()[0].GetValue() == transport {
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	var m message
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, 1.0, clients("websocket"))
+	assert.Zero(t, clients("sse"))
+
+	require.NoError(t, conn.Close())
+	assert.Eventually(t, func() bool { return clients("websocket") == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestGatewayOrigins(t *testing.T) {
+	dial := func(srv *httptest.Server, origin string) int {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", header)
+		if err == nil {
+			conn.Close()
+			return http.StatusSwitchingProtocols
+		}
+		require.NotNil(t, resp, err)
+		return resp.StatusCode
+	}
+
+	srv := setupTestGateway(t)
+	assert.Equal(t, http.StatusSwitchingProtocols, dial(srv, ""), "not a browser")
+	assert.Equal(t, http.StatusSwitchingProtocols, dial(srv, srv.URL), "same origin")
+	assert.Equal(t, http.StatusForbidden, dial(srv, "https://evil.example"))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/sse", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	srv = setupTestGateway(t, WithAllowedOrigins("https://app.example/"))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial(srv, "https://app.example"))
+	assert.Equal(t, http.StatusForbidden, dial(srv, "https://evil.example"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/sse", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://app.example")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "https://app.example", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	srv = setupTestGateway(t, WithAllowedOrigins("*"))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial(srv, "https://evil.example"))
+}
@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSubsystem is the subsystem of the gateway's metrics, the same as the
+// sequencer's.
+const MetricsSubsystem = "dseq"
+
+// Metrics are the metrics of a gateway.
+type Metrics struct {
+	// Stream clients connected to the gateway, labeled by transport. Nodes
+	// also count the external clients of their stream server under transport "tcp".
+	StreamClients metrics.Gauge
+}
+
+// PrometheusMetrics returns metrics registered with Prometheus' default
+// registry, labeled with labelsAndValues.
+func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
+	labels := []string{}
+	for i := 0; i < len(labelsAndValues); i += 2 {
+		labels = append(labels, labelsAndValues[i])
+	}
+	return &Metrics{
+		StreamClients: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "stream_clients",
+			Help:      "Stream clients connected to the node, by transport.",
+		}, append(labels, "transport")).With(labelsAndValues...),
+	}
+}
+
+// NopMetrics returns metrics that record nothing.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		StreamClients: discard.NewGauge(),
+	}
+}
@@ -0,0 +1,147 @@
+package load
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxAuditTxs is how many hashes of missing or duplicated txs are listed per stream.
+const maxAuditTxs = 10
+
+// Audit checks that every tx accepted during a load test was sequenced exactly
+// once, in the same order, in every stream.
+type Audit struct {
+	Submitted uint64        `json:"submitted"`
+	Accepted  uint64        `json:"accepted"` // committed with code 0
+	Streams   []StreamAudit `json:"streams"`
+	SameOrder bool          `json:"same_order"`
+	// Divergence is the position, among the txs of the test sequenced in every
+	// stream, at which the streams first order them differently.
+	Divergence *Divergence `json:"divergence,omitempty"`
+	Passed     bool        `json:"passed"`
+}
+
+// StreamAudit is the audit of one stream.
+type StreamAudit struct {
+	Stream     string        `json:"stream"`
+	Sequenced  uint64        `json:"sequenced"`  // distinct submitted txs in the stream
+	Missing    uint64        `json:"missing"`    // accepted txs not in the stream
+	Duplicated uint64        `json:"duplicated"` // submitted txs in the stream more than once
+	Unexpected uint64        `json:"unexpected"` // txs in the stream that were not submitted
+	MissingTxs []common.Hash `json:"missing_txs,omitempty"`
+	Duplicates []common.Hash `json:"duplicates,omitempty"`
+}
+
+// Divergence is where the streams first order the txs of the test differently.
+type Divergence struct {
+	Position int           `json:"position"`
+	Txs      []common.Hash `json:"txs"` // the tx at Position in each stream
+}
+
+// auditTx is a submitted tx and how many times each stream sequenced it.
+type auditTx struct {
+	accepted bool
+	seen     []int
+}
+
+// audit records every submitted tx and every tx arriving in the streams. It
+// is not safe for concurrent use.
+type audit struct {
+	txs        map[common.Hash]*auditTx
+	accepted   uint64
+	order      [][]common.Hash // per stream, the submitted txs in stream order
+	unexpected []uint64
+}
+
+func newAudit(streams int) *audit {
+	return &audit{
+		txs:        make(map[common.Hash]*auditTx),
+		order:      make([][]common.Hash, streams),
+		unexpected: make([]uint64, streams),
+	}
+}
+
+func (a *audit) submit(hash common.Hash) {
+	a.txs[hash] = &auditTx{seen: make([]int, len(a.order))}
+}
+
+func (a *audit) accept(hash common.Hash) {
+	if tx, ok := a.txs[hash]; ok && !tx.accepted {
+		tx.accepted = true
+		a.accepted++
+	}
+}
+
+func (a *audit) arrive(i int, hash common.Hash) {
+	tx, ok := a.txs[hash]
+	if !ok {
+		a.unexpected[i]++
+		return
+	}
+	tx.seen[i]++
+	if tx.seen[i] == 1 {
+		a.order[i] = append(a.order[i], hash)
+	}
+}
+
+// report audits the streams.
+func (a *audit) report(streams []string) *Audit {
+	r := &Audit{Submitted: uint64(len(a.txs)), Accepted: a.accepted, Passed: true}
+	for i, s := range streams {
+		sa := StreamAudit{Stream: s, Sequenced: uint64(len(a.order[i])), Unexpected: a.unexpected[i]}
+		for hash, tx := range a.txs {
+			switch {
+			case tx.seen[i] == 0 && tx.accepted:
+				sa.Missing++
+				if len(sa.MissingTxs) < maxAuditTxs {
+					sa.MissingTxs = append(sa.MissingTxs, hash)
+				}
+			case tx.seen[i] > 1:
+				sa.Duplicated++
+				if len(sa.Duplicates) < maxAuditTxs {
+					sa.Duplicates = append(sa.Duplicates, hash)
+				}
+			}
+		}
+		r.Passed = r.Passed && sa.Missing == 0 && sa.Duplicated == 0
+		r.Streams = append(r.Streams, sa)
+	}
+
+	r.Divergence = a.divergence()
+	r.SameOrder = r.Divergence == nil
+	r.Passed = r.Passed && r.SameOrder
+	return r
+}
+
+// divergence compares the order of the txs sequenced in every stream, so that
+// a tx missing from one stream is not also reported as a difference in order.
+func (a *audit) divergence() *Divergence {
+	shared := make([][]common.Hash, len(a.order))
+	for i, order := range a.order {
+		for _, hash := range order {
+			if a.inAll(hash) {
+				shared[i] = append(shared[i], hash)
+			}
+		}
+	}
+	for pos := range shared[0] {
+		for i := 1; i < len(shared); i++ {
+			if shared[i][pos] != shared[0][pos] {
+				d := &Divergence{Position: pos}
+				for _, order := range shared {
+					d.Txs = append(d.Txs, order[pos])
+				}
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+func (a *audit) inAll(hash common.Hash) bool {
+	for _, n := range a.txs[hash].seen {
+		if n == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package load
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditReportsMissingDuplicatedAndUnexpected(t *testing.T) {
+	a := newAudit(2)
+	tx1, tx2, tx3, other := common.Hash{1}, common.Hash{2}, common.Hash{3}, common.Hash{9}
+	for _, h := range []common.Hash{tx1, tx2, tx3} {
+		a.submit(h)
+	}
+	a.accept(tx1)
+	a.accept(tx2)
+	// tx3 was not accepted, so it may be missing
+
+	for _, h := range []common.Hash{tx1, tx2, tx2, other} {
+		a.arrive(0, h)
+	}
+	a.arrive(1, tx1)
+
+	r := a.report([]string{"a", "b"})
+	assert.Equal(t, uint64(3), r.Submitted)
+	assert.Equal(t, uint64(2), r.Accepted)
+	assert.Equal(t, StreamAudit{Stream: "a", Sequenced: 2, Duplicated: 1, Unexpected: 1, Duplicates: []common.Hash{tx2}}, r.Streams[0])
+	assert.Equal(t, StreamAudit{Stream: "b", Sequenced: 1, Missing: 1, MissingTxs: []common.Hash{tx2}}, r.Streams[1])
+	assert.True(t, r.SameOrder)
+	assert.False(t, r.Passed)
+}
+
+func TestAuditReportsDivergentOrder(t *testing.T) {
+	a := newAudit(2)
+	tx1, tx2, tx3 := common.Hash{1}, common.Hash{2}, common.Hash{3}
+	for _, h := range []common.Hash{tx1, tx2, tx3} {
+		a.submit(h)
+		a.accept(h)
+	}
+	for _, h := range []common.Hash{tx1, tx2, tx3} {
+		a.arrive(0, h)
+	}
+	for _, h := range []common.Hash{tx1, tx3, tx2} {
+		a.arrive(1, h)
+	}
+
+	r := a.report([]string{"a", "b"})
+	assert.False(t, r.SameOrder)
+	assert.Equal(t, &Divergence{Position: 1, Txs: []common.Hash{tx2, tx3}}, r.Divergence)
+	assert.False(t, r.Passed)
+}
+
+func TestRunAudit(t *testing.T) {
+	s1, s2 := streamtest.NewServer(t), streamtest.NewServer(t)
+	node := sequencingNode(t, []*streamtest.Server{s1, s2}, []time.Duration{0, 0})
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node},
+		Requests:    20,
+		Concurrency: 4,
+		Streams:     []string{s1.Addr, s2.Addr},
+		StreamWait:  10 * time.Second,
+		Audit:       true,
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, report.Audit)
+	assert.Equal(t, uint64(20), report.Audit.Accepted)
+	for _, s := range report.Audit.Streams {
+		assert.Equal(t, uint64(20), s.Sequenced)
+	}
+	assert.True(t, report.Audit.SameOrder)
+	assert.True(t, report.Audit.Passed)
+
+	_, err = Run(context.Background(), Config{Nodes: []string{node}, Requests: 1, Concurrency: 1, Audit: true})
+	assert.Error(t, err)
+}
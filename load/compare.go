@@ -0,0 +1,151 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+)
+
+// Delta is the change in one metric of a phase between two runs.
+type Delta struct {
+	Phase      string  `json:"phase"`
+	Metric     string  `json:"metric"`
+	Old        float64 `json:"old"`
+	New        float64 `json:"new"`
+	Change     float64 `json:"change"`     // relative to Old, or absolute for error rates
+	Regression bool    `json:"regression"` // whether Change is worse than tolerated
+}
+
+// Comparison is how the results of a run compare to those of an earlier one.
+type Comparison struct {
+	Deltas      []Delta  `json:"deltas"`
+	Notes       []string `json:"notes,omitempty"` // phases that cannot be compared, or ran differently
+	Regressions int      `json:"regressions"`
+}
+
+// Tolerances bound the changes between two runs that are not regressions.
+type Tolerances struct {
+	Relative  float64 // most relative increase in latency, or decrease in throughput
+	ErrorRate float64 // most absolute increase in the fraction of txs not OK
+}
+
+// metric is a figure of a phase that can regress.
+type metric struct {
+	name        string
+	value       func(*Report) float64
+	higherWorse bool
+	errorRate   bool
+}
+
+var compared = []metric{
+	{name: "throughput tx/s", value: func(r *Report) float64 { return r.Total.Throughput }},
+	{name: "p50 ms", value: func(r *Report) float64 { return r.Total.Latency.P50 }, higherWorse: true},
+	{name: "p90 ms", value: func(r *Report) float64 { return r.Total.Latency.P90 }, higherWorse: true},
+	{name: "p99 ms", value: func(r *Report) float64 { return r.Total.Latency.P99 }, higherWorse: true},
+	{name: "max ms", value: func(r *Report) float64 { return r.Total.Latency.Max }, higherWorse: true},
+	{name: "error rate", value: errorRate, higherWorse: true, errorRate: true},
+	{name: "stream p99 ms", value: streamP99, higherWorse: true},
+}
+
+// errorRate returns the fraction of txs sent that were not OK.
+func errorRate(r *Report) float64 {
+	if r.Total.Requests == 0 {
+		return 0
+	}
+	return float64(r.Total.Requests-r.Total.OK) / float64(r.Total.Requests)
+}
+
+// streamP99 returns the highest p99 submit-to-stream latency of any stream.
+func streamP99(r *Report) float64 {
+	var p99 float64
+	for _, s := range r.Streams {
+		p99 = max(p99, s.Latency.P99)
+	}
+	return p99
+}
+
+// Compare compares the phases of the results after a change with those of the
+// same name before it, flagging the metrics that got worse by more than t tolerates.
+func Compare(before, after *Results, t Tolerances) *Comparison {
+	c := &Comparison{}
+	oldPhases := make(map[string]PhaseReport)
+	for _, p := range before.phases() {
+		oldPhases[p.Name] = p
+	}
+	newPhases := make(map[string]bool)
+
+	for _, np := range after.phases() {
+		newPhases[np.Name] = true
+		op, ok := oldPhases[np.Name]
+		if !ok {
+			c.Notes = append(c.Notes, fmt.Sprintf("phase %s is not in the old results", phaseName(np.Name)))
+			continue
+		}
+		if op.Report.Config != nil && np.Report.Config != nil && !reflect.DeepEqual(op.Report.Config, np.Report.Config) {
+			c.Notes = append(c.Notes, fmt.Sprintf("phase %s ran with a different configuration", phaseName(np.Name)))
+		}
+		for _, m := range compared {
+			d := Delta{Phase: np.Name, Metric: m.name, Old: m.value(op.Report), New: m.value(np.Report)}
+			if d.Old == 0 && d.New == 0 {
+				continue
+			}
+			worse := d.New - d.Old
+			if !m.higherWorse {
+				worse = -worse
+			}
+			if m.errorRate {
+				d.Change = d.New - d.Old
+				d.Regression = worse > t.ErrorRate
+			} else {
+				if d.Old != 0 {
+					d.Change = (d.New - d.Old) / d.Old
+				}
+				d.Regression = worse > t.Relative*d.Old
+			}
+			if d.Regression {
+				c.Regressions++
+			}
+			c.Deltas = append(c.Deltas, d)
+		}
+	}
+	for _, op := range before.phases() {
+		if !newPhases[op.Name] {
+			c.Notes = append(c.Notes, fmt.Sprintf("phase %s is not in the new results", phaseName(op.Name)))
+		}
+	}
+	return c
+}
+
+// phaseName returns the name of a phase, in which a single test is unnamed.
+func phaseName(name string) string {
+	if name == "" {
+		return "(single test)"
+	}
+	return name
+}
+
+// Print writes the comparison in a human-readable form.
+func (c *Comparison) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "phase\tmetric\told\tnew\tchange\t\t")
+	for _, d := range c.Deltas {
+		change := fmt.Sprintf("%+.1f%%", d.Change*100)
+		if d.Metric == "error rate" {
+			change = fmt.Sprintf("%+.4f", d.Change)
+		}
+		flag := ""
+		if d.Regression {
+			flag = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.4g\t%.4g\t%s\t%s\t\n", phaseName(d.Phase), d.Metric, d.Old, d.New, change, flag)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, n := range c.Notes {
+		fmt.Fprintf(w, "Note: %s\n", n)
+	}
+	fmt.Fprintf(w, "Regressions: %d\n", c.Regressions)
+	return nil
+}
@@ -0,0 +1,284 @@
+// Package load generates tx load against dseq nodes and measures how they cope:
+// latency percentiles, throughput, and which txs failed and why.
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Config describes a load test. With Stages set the test is open-loop: txs are
+// sent at the stages' rates, up to MaxInFlight at a time. Otherwise it is
+// closed-loop: Requests txs are sent, Concurrency at a time.
+type Config struct {
+	Nodes       []string // CometBFT RPC addresses (host:port) to send txs to
+	Requests    int      // number of txs to send
+	Concurrency int      // number of txs in flight
+	Stages      []Stage  // target rates of an open-loop test
+	MaxInFlight int      // bound on txs in flight in an open-loop test
+
+	Streams    []string      // data stream addresses (host:port) of nodes to match the txs in
+	StreamWait time.Duration // how long to wait for committed txs to reach the streams
+	Audit      bool          // whether to audit that the streams sequenced every accepted tx once
+
+	Payloads   Payloads            // payloads of the txs, 32 random bytes if nil
+	Format     Format              // how payloads are sent as txs
+	Namespaces []string            // namespaces of enveloped txs, picked at random
+	Keys       []*ecdsa.PrivateKey // producer keys of signed txs
+	Producers  int                 // producers of signed txs, with keys generated for those not in Keys
+	Nonces     *Nonces             // nonces to continue from, such as an earlier phase's, new ones if nil
+
+	Mode      Mode      // broadcast mode, commit by default
+	Transport Transport // how requests reach the nodes, GET by default
+	Batch     int       // txs per request with the POST and WebSocket transports, 1 if 0
+}
+
+// RunConfig is the configuration of a load test as it is reported.
+type RunConfig struct {
+	Nodes       []string      `json:"nodes"`
+	Requests    int           `json:"requests,omitempty"`
+	Concurrency int           `json:"concurrency,omitempty"`
+	Stages      []Stage       `json:"stages,omitempty"`
+	MaxInFlight int           `json:"max_in_flight,omitempty"`
+	Streams     []string      `json:"streams,omitempty"`
+	StreamWait  time.Duration `json:"stream_wait,omitempty"`
+	Audit       bool          `json:"audit,omitempty"`
+	Payloads    string        `json:"payloads"`
+	Format      string        `json:"format"`
+	Namespaces  []string      `json:"namespaces,omitempty"`
+	Producers   int           `json:"producers,omitempty"`
+	Mode        string        `json:"mode"`
+	Transport   string        `json:"transport"`
+	Batch       int           `json:"batch"`
+}
+
+// reported returns the configuration to report. Keys are left out.
+func (c Config) reported() *RunConfig {
+	rc := &RunConfig{
+		Nodes:      c.Nodes,
+		Streams:    c.Streams,
+		Audit:      c.Audit,
+		Payloads:   describePayloads(c.Payloads),
+		Format:     c.Format.String(),
+		Namespaces: c.Namespaces,
+		Mode:       c.Mode.String(),
+		Transport:  c.Transport.String(),
+		Batch:      c.batch(),
+	}
+	if len(c.Stages) > 0 {
+		rc.Stages, rc.MaxInFlight = c.Stages, c.MaxInFlight
+	} else {
+		rc.Requests, rc.Concurrency = c.Requests, c.Concurrency
+	}
+	if len(c.Streams) > 0 {
+		rc.StreamWait = c.StreamWait
+	}
+	if c.Format == FormatSigned {
+		rc.Producers = c.producers()
+	}
+	return rc
+}
+
+// batch returns the number of txs to send per request.
+func (c Config) batch() int {
+	return max(c.Batch, 1)
+}
+
+// producers returns how many producers sign txs of the signed format: one per
+// key, and at least Producers.
+func (c Config) producers() int {
+	return max(c.Producers, len(c.Keys), 1)
+}
+
+// checksNonceOrder returns whether the streams are checked for producers' txs
+// out of nonce order. Proposals shuffle txs, so a producer's txs only reach
+// the streams in order if each is committed before the next is sent: one tx
+// per request, in commit mode.
+func (c Config) checksNonceOrder() bool {
+	return c.Format == FormatSigned && len(c.Streams) > 0 && c.Mode == ModeCommit && c.batch() == 1
+}
+
+func (c Config) validate() error {
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("no nodes to send load to")
+	}
+	if c.Audit && len(c.Streams) == 0 {
+		return fmt.Errorf("an audit needs streams to read")
+	}
+	if c.Batch < 0 {
+		return fmt.Errorf("batch must not be negative")
+	}
+	if c.batch() > 1 && c.Transport == TransportGet {
+		return fmt.Errorf("batching needs the post or ws transport")
+	}
+	if c.Format == FormatSigned && c.producers() > 1 {
+		if len(c.Streams) == 0 {
+			return fmt.Errorf("checking the nonce order of producers needs streams to read")
+		}
+		if !c.checksNonceOrder() {
+			return fmt.Errorf("the nonce order of producers can only be checked in commit mode with batch 1")
+		}
+	}
+	if len(c.Stages) > 0 {
+		for _, s := range c.Stages {
+			if s.From < 0 || s.To < 0 || s.From > maxRate || s.To > maxRate || s.Duration <= 0 {
+				return fmt.Errorf("invalid stage: %s", s)
+			}
+		}
+		if c.MaxInFlight <= 0 {
+			return fmt.Errorf("max in flight must be greater than 0")
+		}
+		return nil
+	}
+	if c.Requests <= 0 {
+		return fmt.Errorf("requests must be greater than 0")
+	}
+	if r, ok := c.Payloads.(*replayPayloads); ok && !r.cycle && c.Requests > len(r.lines) {
+		return fmt.Errorf("replay file %s has %d payloads, fewer than the %d txs to send without repeats", r.path, len(r.lines), c.Requests)
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be greater than 0")
+	}
+	return nil
+}
+
+// Run sends txs, each to a random node, as cfg describes and reports how the
+// nodes handled them. Cancelling ctx stops sending and reports the txs sent so far.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	txs, err := newTxSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var producers []common.Address
+	if cfg.checksNonceOrder() {
+		producers = txs.addresses
+	}
+	tracker, err := newTracker(ctx, cfg.Streams, cfg.Audit, producers)
+	if err != nil {
+		return nil, err
+	}
+
+	conns := cfg.Concurrency
+	if len(cfg.Stages) > 0 {
+		conns = cfg.MaxInFlight
+	}
+	sender, err := NewSender(conns, WithMode(cfg.Mode), WithTransport(cfg.Transport))
+	if err != nil {
+		return nil, err
+	}
+	defer sender.Close()
+	rn := &runner{nodes: cfg.Nodes, sender: sender, txs: txs, tracker: tracker}
+
+	var report *Report
+	if len(cfg.Stages) > 0 {
+		report, err = runStages(ctx, cfg, rn)
+	} else {
+		report = runRequests(ctx, cfg, rn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Config = cfg.reported()
+	report.Mode = cfg.Mode.String()
+	if tracker == nil {
+		return report, nil
+	}
+
+	report.Streams, report.Skew, err = tracker.finish(ctx, cfg.StreamWait)
+	if err != nil {
+		return nil, err
+	}
+	if tracker.audit != nil {
+		report.Audit = tracker.audit.report(cfg.Streams)
+	}
+	if tracker.order != nil {
+		report.Producers = tracker.order.report()
+		inOrder := true
+		for _, p := range report.Producers {
+			inOrder = inOrder && p.OutOfOrder == 0
+		}
+		report.InNonceOrder = &inOrder
+	}
+	return report, nil
+}
+
+// runRequests runs a closed-loop test.
+func runRequests(ctx context.Context, cfg Config, rn *runner) *Report {
+	stats := NewStats()
+
+	// each job is a request for a batch of txs
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for sent := 0; sent < cfg.Requests; {
+			n := min(cfg.batch(), cfg.Requests-sent)
+			select {
+			case jobs <- n:
+				sent += n
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				for _, r := range rn.send(ctx, n) {
+					if ctx.Err() != nil && r.Outcome != OK {
+						// interrupted, not failed
+						continue
+					}
+					stats.Add(r)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return stats.Report(time.Since(start))
+}
+
+// runner makes the txs of a test and sends them.
+type runner struct {
+	nodes   []string
+	sender  *Sender
+	txs     *txSource
+	tracker *tracker
+}
+
+// send submits n new txs to a random node in one request.
+func (rn *runner) send(ctx context.Context, n int) []Result {
+	node := rn.nodes[mrand.Intn(len(rn.nodes))]
+	txs, release, err := rn.txs.take(ctx, n)
+	if err != nil {
+		results := make([]Result, n)
+		for i := range results {
+			results[i] = Result{Node: node, Outcome: HTTPError, Err: fmt.Errorf("failed to make tx: %w", err)}
+		}
+		return results
+	}
+	defer release()
+
+	for _, tx := range txs {
+		rn.tracker.submit(tx)
+	}
+	results := rn.sender.SendBatch(ctx, node, txs)
+	for i, r := range results {
+		rn.tracker.done(txs[i], r.Outcome == OK)
+	}
+	return results
+}
@@ -0,0 +1,140 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	okResponse       = `{"jsonrpc":"2.0","id":-1,"result":{"check_tx":{"code":0},"tx_result":{"code":0},"hash":"AB","height":"5"}}`
+	checkTxResponse  = `{"jsonrpc":"2.0","id":-1,"result":{"check_tx":{"code":3,"log":"bad envelope"},"tx_result":{},"hash":"AB","height":"0"}}`
+	txResultResponse = `{"jsonrpc":"2.0","id":-1,"result":{"check_tx":{"code":0},"tx_result":{"code":7,"log":"failed"},"hash":"AB","height":"5"}}`
+	rpcErrorResponse = `{"jsonrpc":"2.0","id":-1,"error":{"code":-32603,"message":"Internal error","data":"tx already exists in cache"}}`
+)
+
+// fakeNode answers broadcast_tx_commit with the given status and body, and
+// counts the requests it receives.
+func fakeNode(t *testing.T, status int, body string) (string, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/broadcast_tx_commit", r.URL.Path)
+		assert.True(t, strings.HasPrefix(r.URL.Query().Get("tx"), "0x"))
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://"), &requests
+}
+
+func TestSendClassifiesResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		outcome Outcome
+		code    uint32
+	}{
+		{name: "committed", status: http.StatusOK, body: okResponse, outcome: OK},
+		{name: "rejected by CheckTx", status: http.StatusOK, body: checkTxResponse, outcome: CheckTxRejected, code: 3},
+		{name: "rejected in block", status: http.StatusOK, body: txResultResponse, outcome: TxRejected, code: 7},
+		{name: "JSON-RPC error", status: http.StatusInternalServerError, body: rpcErrorResponse, outcome: RPCError},
+		{name: "HTTP error", status: http.StatusBadGateway, body: "bad gateway", outcome: HTTPError},
+	}
+
+	sender, err := NewSender(1)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, _ := fakeNode(t, tt.status, tt.body)
+			r := sender.Send(context.Background(), node, []byte{1, 2, 3})
+			assert.Equal(t, tt.outcome, r.Outcome, r.Err)
+			assert.Equal(t, tt.code, r.Code)
+			assert.Equal(t, tt.outcome == OK, r.Err == nil)
+			assert.Positive(t, r.Latency)
+		})
+	}
+}
+
+func TestSendConnectionError(t *testing.T) {
+	// nothing listens on port 1
+	for _, transport := range []Transport{TransportGet, TransportPost, TransportWebSocket} {
+		sender, err := NewSender(1, WithTransport(transport))
+		require.NoError(t, err)
+		r := sender.Send(context.Background(), "127.0.0.1:1", nil)
+		assert.Equal(t, HTTPError, r.Outcome, transport)
+		assert.Error(t, r.Err, transport)
+	}
+}
+
+func TestRunReportsPerNode(t *testing.T) {
+	good, goodRequests := fakeNode(t, http.StatusOK, okResponse)
+	bad, badRequests := fakeNode(t, http.StatusOK, checkTxResponse)
+
+	report, err := Run(context.Background(), Config{Nodes: []string{good, bad}, Requests: 50, Concurrency: 4})
+	require.NoError(t, err)
+
+	total := report.Total
+	assert.Equal(t, uint64(50), total.Requests)
+	assert.Equal(t, uint64(goodRequests.Load()), total.OK)
+	assert.Equal(t, uint64(badRequests.Load()), total.CheckTxRejected)
+	assert.Positive(t, total.Throughput)
+	assert.Positive(t, total.Latency.Max)
+	assert.LessOrEqual(t, total.Latency.P50, total.Latency.P99)
+
+	require.Len(t, report.Nodes, 2)
+	for _, n := range report.Nodes {
+		switch n.Node {
+		case good:
+			assert.Equal(t, n.Requests, n.OK)
+		case bad:
+			assert.Equal(t, n.Requests, n.CheckTxRejected)
+			assert.Zero(t, n.Latency.Max)
+		}
+	}
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, "check_tx_rejected: CheckTx code 3: bad envelope", report.Errors[0].Error)
+}
+
+func TestRunValidatesConfig(t *testing.T) {
+	_, err := Run(context.Background(), Config{Nodes: []string{"a"}, Requests: 0, Concurrency: 1})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), Config{Requests: 1, Concurrency: 1})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), Config{Nodes: []string{"a"}, Requests: 1, Concurrency: 1, Batch: 2})
+	assert.Error(t, err)
+
+	// proposals shuffle txs, so producers' nonce order only holds one committed tx at a time
+	producers := Config{Nodes: []string{"a"}, Requests: 1, Concurrency: 1, Format: FormatSigned, Producers: 2}
+	_, err = Run(context.Background(), producers)
+	assert.ErrorContains(t, err, "needs streams")
+	producers.Streams = []string{"s"}
+	for _, c := range []Config{
+		{Mode: ModeSync},
+		{Mode: ModeAsync},
+		{Transport: TransportPost, Batch: 2},
+	} {
+		c.Nodes, c.Requests, c.Concurrency = producers.Nodes, producers.Requests, producers.Concurrency
+		c.Format, c.Producers, c.Streams = producers.Format, producers.Producers, producers.Streams
+		_, err = Run(context.Background(), c)
+		assert.ErrorContains(t, err, "commit mode with batch 1")
+		assert.False(t, c.checksNonceOrder())
+	}
+	assert.True(t, producers.checksNonceOrder())
+
+	// each key is a producer, whatever Producers says
+	keys := Config{Nodes: []string{"a"}, Requests: 1, Concurrency: 1, Format: FormatSigned, Keys: make([]*ecdsa.PrivateKey, 5)}
+	_, err = Run(context.Background(), keys)
+	assert.ErrorContains(t, err, "needs streams")
+	assert.Equal(t, 5, keys.reported().Producers)
+}
@@ -0,0 +1,324 @@
+package load
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version identifies the build of dseq that ran a load test.
+type Version struct {
+	Version    string `json:"version"`
+	GitCommit  string `json:"git_commit"`
+	AppVersion uint64 `json:"app_version"`
+}
+
+// Results are the results of a load run, of a single test or a scenario, in
+// the form written by Write and read by ReadResults.
+type Results struct {
+	Version  Version         `json:"version"`
+	Started  time.Time       `json:"started"`
+	Report   *Report         `json:"report,omitempty"`
+	Scenario *ScenarioReport `json:"scenario,omitempty"`
+}
+
+// ReadResults reads results written as JSON.
+func ReadResults(path string) (*Results, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	var r Results
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse results %s: %w", path, err)
+	}
+	if r.Report == nil && r.Scenario == nil {
+		return nil, fmt.Errorf("%s has no load test results", path)
+	}
+	return &r, nil
+}
+
+// phases returns the reports of the phases of a scenario, or of a single
+// test as one unnamed phase.
+func (r *Results) phases() []PhaseReport {
+	if r.Scenario != nil {
+		return r.Scenario.Phases
+	}
+	if r.Report == nil {
+		return nil
+	}
+	failures := Thresholds{}.Check(r.Report)
+	return []PhaseReport{{Report: r.Report, Failures: failures, Passed: len(failures) == 0}}
+}
+
+// passed returns whether every phase passed.
+func (r *Results) passed() bool {
+	if r.Scenario != nil {
+		return r.Scenario.Passed
+	}
+	for _, p := range r.phases() {
+		if !p.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// ReportFormat is a machine-readable form of load test results.
+type ReportFormat int
+
+const (
+	ReportJSON       ReportFormat = iota // the results as one JSON document
+	ReportCSV                            // a row per phase and node
+	ReportPrometheus                     // metrics for the node exporter's textfile collector
+)
+
+func (f ReportFormat) String() string {
+	switch f {
+	case ReportJSON:
+		return "json"
+	case ReportCSV:
+		return "csv"
+	case ReportPrometheus:
+		return "prometheus-textfile"
+	default:
+		return fmt.Sprintf("report_format_%d", int(f))
+	}
+}
+
+// ParseReportFormat parses a report format: json, csv or prometheus-textfile.
+func ParseReportFormat(s string) (ReportFormat, error) {
+	for _, f := range []ReportFormat{ReportJSON, ReportCSV, ReportPrometheus} {
+		if s == f.String() {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown report format %q, expected json, csv or prometheus-textfile", s)
+}
+
+// Write writes the results in format f.
+func (r *Results) Write(w io.Writer, f ReportFormat) error {
+	switch f {
+	case ReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case ReportCSV:
+		return r.writeCSV(w)
+	case ReportPrometheus:
+		return r.writePrometheus(w)
+	default:
+		return fmt.Errorf("unknown report format %s", f)
+	}
+}
+
+// WriteFile writes the results to path in format f. The file is replaced
+// atomically, so that a collector never reads it half written.
+func (r *Results) WriteFile(path string, f ReportFormat) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".report-*")
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := r.Write(tmp, f); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// csvHeader names the columns of a CSV report.
+var csvHeader = []string{
+	"version", "git_commit", "phase", "node", "requests", "ok", "http_errors", "rpc_errors",
+	"check_tx_rejected", "tx_rejected", "throughput", "p50_ms", "p90_ms", "p99_ms", "max_ms", "mean_ms", "passed",
+}
+
+// writeCSV writes a row for every node of every phase, after a row of the
+// phase's total, whose node is "all".
+func (r *Results) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	for _, p := range r.phases() {
+		rows := append([]NodeSummary{{Node: "all", Summary: p.Report.Total}}, p.Report.Nodes...)
+		for _, n := range rows {
+			err := cw.Write([]string{
+				r.Version.Version, r.Version.GitCommit, p.Name, n.Node,
+				u(n.Requests), u(n.OK), u(n.HTTPErrors), u(n.RPCErrors), u(n.CheckTxRejected), u(n.TxRejected),
+				f(n.Throughput), f(n.Latency.P50), f(n.Latency.P90), f(n.Latency.P99), f(n.Latency.Max), f(n.Latency.Mean),
+				strconv.FormatBool(p.Passed),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// promFamily is a metric family of a Prometheus report and its samples.
+type promFamily struct {
+	name, typ, help string
+	samples         []string
+}
+
+// promWriter collects metrics in the Prometheus text format, in which every
+// family's samples must be written together.
+type promWriter struct {
+	families []*promFamily
+	byName   map[string]*promFamily
+}
+
+// labelEscaper escapes label values as the text format expects.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// family returns the family called name, adding it if it is new.
+func (pw *promWriter) family(name, typ, help string) *promFamily {
+	if f, ok := pw.byName[name]; ok {
+		return f
+	}
+	f := &promFamily{name: name, typ: typ, help: help}
+	pw.families = append(pw.families, f)
+	pw.byName[name] = f
+	return f
+}
+
+// add adds a sample of the family, with a suffix such as _bucket for
+// histograms, labeled with name and value pairs.
+func (f *promFamily) add(suffix string, labels []string, value float64) {
+	var sb strings.Builder
+	sb.WriteString(f.name + suffix)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		sb.WriteByte('}')
+	}
+	fmt.Fprintf(&sb, " %s", strconv.FormatFloat(value, 'g', -1, 64))
+	f.samples = append(f.samples, sb.String())
+}
+
+// gauge adds a sample of a gauge.
+func (pw *promWriter) gauge(name, help string, labels []string, value float64) {
+	pw.family(name, "gauge", help).add("", labels, value)
+}
+
+// histogram adds the buckets of a latency histogram, in seconds.
+func (pw *promWriter) histogram(name, help string, labels []string, h *Histogram) {
+	if h == nil {
+		return
+	}
+	f := pw.family(name, "histogram", help)
+	for _, b := range h.Buckets {
+		f.add("_bucket", with(labels, "le", strconv.FormatFloat(b.LE/1000, 'g', -1, 64)), float64(b.Count))
+	}
+	f.add("_bucket", with(labels, "le", "+Inf"), float64(h.Count))
+	f.add("_sum", labels, h.Sum/1000)
+	f.add("_count", labels, float64(h.Count))
+}
+
+func (pw *promWriter) writeTo(w io.Writer) error {
+	for _, f := range pw.families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+			return err
+		}
+		for _, s := range f.samples {
+			if _, err := fmt.Fprintln(w, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// with returns labels followed by more, without changing labels.
+func with(labels []string, more ...string) []string {
+	return append(append([]string{}, labels...), more...)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writePrometheus writes the results as metrics for the node exporter's
+// textfile collector. Every sample of a phase is labeled with its name.
+func (r *Results) writePrometheus(w io.Writer) error {
+	pw := &promWriter{byName: make(map[string]*promFamily)}
+	pw.gauge("dseq_load_info", "Build of dseq that ran the load test.",
+		[]string{"version", r.Version.Version, "git_commit", r.Version.GitCommit,
+			"app_version", strconv.FormatUint(r.Version.AppVersion, 10)}, 1)
+	pw.gauge("dseq_load_start_time_seconds", "When the load test started.", nil, float64(r.Started.UnixNano())/1e9)
+	pw.gauge("dseq_load_passed", "Whether every phase of the load test passed.", nil, boolValue(r.passed()))
+
+	for _, p := range r.phases() {
+		phase := []string{"phase", p.Name}
+		rep := p.Report
+		if c := rep.Config; c != nil {
+			pw.gauge("dseq_load_phase_config_info", "Settings of a phase of the load test.",
+				with(phase, "mode", c.Mode, "transport", c.Transport, "format", c.Format,
+					"payloads", c.Payloads, "batch", strconv.Itoa(c.Batch)), 1)
+		}
+		pw.gauge("dseq_load_phase_passed", "Whether a phase of the load test met its thresholds.",
+			phase, boolValue(p.Passed))
+		pw.gauge("dseq_load_phase_duration_seconds", "How long a phase of the load test ran.",
+			phase, rep.Elapsed.Seconds())
+		pw.gauge("dseq_load_throughput_tx_per_second", "OK txs per second.", phase, rep.Total.Throughput)
+
+		txs := pw.family("dseq_load_txs_total", "counter", "Txs sent, by node and outcome.")
+		for _, n := range rep.Nodes {
+			for _, o := range []struct {
+				outcome Outcome
+				count   uint64
+			}{
+				{OK, n.OK}, {HTTPError, n.HTTPErrors}, {RPCError, n.RPCErrors},
+				{CheckTxRejected, n.CheckTxRejected}, {TxRejected, n.TxRejected},
+			} {
+				txs.add("", with(phase, "node", n.Node, "outcome", o.outcome.String()), float64(o.count))
+			}
+		}
+
+		l := rep.Total.Latency
+		for _, q := range []struct {
+			quantile string
+			ms       float64
+		}{{"0.5", l.P50}, {"0.9", l.P90}, {"0.99", l.P99}, {"1", l.Max}} {
+			pw.gauge("dseq_load_latency_quantile_seconds", "Latency of OK txs at a quantile.",
+				with(phase, "quantile", q.quantile), q.ms/1000)
+		}
+		pw.histogram("dseq_load_latency_seconds", "Latency of OK txs.", phase, rep.Histogram)
+		for _, s := range rep.Streams {
+			labels := with(phase, "stream", s.Stream)
+			pw.gauge("dseq_load_stream_missing_txs", "Committed txs that did not reach a stream in time.",
+				labels, float64(s.Missing))
+			pw.histogram("dseq_load_stream_latency_seconds",
+				"Latency from submitting a tx to its arrival in a stream.", labels, s.Histogram)
+		}
+	}
+	return pw.writeTo(w)
+}
@@ -0,0 +1,182 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	assert.Nil(t, h.histogram())
+	for _, d := range []time.Duration{500 * time.Microsecond, 3 * time.Millisecond, 3 * time.Millisecond, 150 * time.Millisecond} {
+		h.record(d)
+	}
+
+	out := h.histogram()
+	require.NotNil(t, out)
+	assert.Equal(t, uint64(4), out.Count)
+	assert.InDelta(t, 156.5, out.Sum, 0.5)
+	counts := make(map[float64]uint64)
+	for _, b := range out.Buckets {
+		counts[b.LE] = b.Count
+	}
+	assert.Equal(t, uint64(1), counts[1])
+	assert.Equal(t, uint64(1), counts[2])
+	assert.Equal(t, uint64(3), counts[5])
+	assert.Equal(t, uint64(3), counts[100])
+	assert.Equal(t, uint64(4), counts[200])
+	assert.Equal(t, uint64(4), out.Buckets[len(out.Buckets)-1].Count)
+
+	// latencies beyond the range are counted in the last bucket
+	h.record(10 * time.Minute)
+	out = h.histogram()
+	assert.Equal(t, uint64(4), out.Buckets[len(out.Buckets)-2].Count)
+	assert.Equal(t, uint64(5), out.Buckets[len(out.Buckets)-1].Count)
+}
+
+func testResults(t *testing.T) *Results {
+	t.Helper()
+	node, _ := fakeNode(t, http.StatusOK, okResponse)
+	report, err := Run(context.Background(), Config{Nodes: []string{node}, Requests: 20, Concurrency: 2})
+	require.NoError(t, err)
+	return &Results{
+		Version: Version{Version: "v1.2.3", GitCommit: "abc1234", AppVersion: 1},
+		Started: time.Unix(1700000000, 0).UTC(),
+		Report:  report,
+	}
+}
+
+func TestResultsJSON(t *testing.T) {
+	results := testResults(t)
+	require.NotNil(t, results.Report.Config)
+	assert.Equal(t, "random fixed:32", results.Report.Config.Payloads)
+	assert.Equal(t, 20, results.Report.Config.Requests)
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	require.NoError(t, results.WriteFile(path, ReportJSON))
+	read, err := ReadResults(path)
+	require.NoError(t, err)
+	assert.Equal(t, results.Version, read.Version)
+	assert.Equal(t, results.Report.Total, read.Report.Total)
+	assert.Equal(t, results.Report.Config, read.Report.Config)
+	assert.Equal(t, results.Report.Histogram, read.Report.Histogram)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":{}}`), 0o644))
+	_, err = ReadResults(path)
+	assert.Error(t, err)
+}
+
+func TestResultsCSV(t *testing.T) {
+	results := testResults(t)
+	var buf bytes.Buffer
+	require.NoError(t, results.Write(&buf, ReportCSV))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"v1.2.3", "abc1234", "", "all", "20", "20"}, rows[1][:6])
+	assert.Equal(t, results.Report.Nodes[0].Node, rows[2][3])
+	assert.Equal(t, "true", rows[2][len(rows[2])-1])
+}
+
+func TestResultsPrometheus(t *testing.T) {
+	results := testResults(t)
+	results.Scenario = &ScenarioReport{Passed: true, Phases: []PhaseReport{
+		{Name: "warmup", Report: results.Report, Passed: true},
+		{Name: `spi"ke`, Report: results.Report, Passed: true},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, results.Write(&buf, ReportPrometheus))
+	out := buf.String()
+
+	assert.Contains(t, out, `dseq_load_info{version="v1.2.3",git_commit="abc1234",app_version="1"} 1`)
+	assert.Contains(t, out, `dseq_load_phase_passed{phase="spi\"ke"} 1`)
+	assert.Contains(t, out, `dseq_load_latency_seconds_bucket{phase="warmup",le="+Inf"} 20`)
+	assert.Contains(t, out, `dseq_load_latency_seconds_count{phase="warmup"} 20`)
+	node := results.Report.Nodes[0].Node
+	assert.Contains(t, out, `dseq_load_txs_total{phase="warmup",node="`+node+`",outcome="ok"} 20`)
+
+	// every family is declared once, before all of its samples
+	seen := make(map[string]bool)
+	var current string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			current = strings.Fields(name)[0]
+			assert.False(t, seen[current], current)
+			seen[current] = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(line, current), line)
+	}
+}
+
+func TestParseReportFormat(t *testing.T) {
+	for _, s := range []string{"json", "csv", "prometheus-textfile"} {
+		f, err := ParseReportFormat(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, f.String())
+	}
+	_, err := ParseReportFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	phase := func(name string, throughput, p99 float64, ok uint64) PhaseReport {
+		return PhaseReport{Name: name, Report: &Report{
+			Config: &RunConfig{Nodes: []string{"a"}},
+			Total:  Summary{Requests: 1000, OK: ok, Throughput: throughput, Latency: Latency{P99: p99}},
+		}}
+	}
+	before := &Results{Scenario: &ScenarioReport{Phases: []PhaseReport{
+		phase("steady", 100, 50, 1000),
+		phase("spike", 500, 200, 990),
+		phase("soak", 100, 50, 1000),
+	}}}
+	after := &Results{Scenario: &ScenarioReport{Phases: []PhaseReport{
+		phase("steady", 98, 54, 1000),
+		phase("spike", 400, 300, 970),
+		phase("new", 1, 1, 1),
+	}}}
+	after.Scenario.Phases[0].Report.Config.Nodes = []string{"b"}
+
+	c := Compare(before, after, Tolerances{Relative: 0.1, ErrorRate: 0.001})
+	regressions := make(map[string]bool)
+	for _, d := range c.Deltas {
+		if d.Regression {
+			regressions[d.Phase+" "+d.Metric] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"spike throughput tx/s": true,
+		"spike p99 ms":          true,
+		"spike error rate":      true,
+	}, regressions)
+	assert.Equal(t, 3, c.Regressions)
+	assert.Equal(t, []string{
+		"phase steady ran with a different configuration",
+		"phase new is not in the old results",
+		"phase soak is not in the new results",
+	}, c.Notes)
+
+	var out strings.Builder
+	require.NoError(t, c.Print(&out))
+	assert.Contains(t, out.String(), "REGRESSION")
+	assert.Contains(t, out.String(), "Regressions: 3")
+
+	// improvements are never regressions
+	assert.Zero(t, Compare(after, before, Tolerances{Relative: 0.1, ErrorRate: 0.001}).Regressions)
+}
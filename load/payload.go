@@ -0,0 +1,270 @@
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultPayloadSize is the size of random payloads unless a distribution is given.
+	defaultPayloadSize = 32
+
+	// defaultZipfS is the Zipf exponent unless one is given; larger is more skewed.
+	defaultZipfS = 1.1
+
+	// maxReplayLine bounds the length of a line of a replay file.
+	maxReplayLine = 16 << 20
+)
+
+// Sizes is a distribution of payload sizes.
+type Sizes interface {
+	// Sampler returns a function drawing sizes with r, which it is not safe
+	// to call concurrently.
+	Sampler(r *rand.Rand) func() int
+	String() string
+}
+
+// FixedSize is a distribution of a single size.
+type FixedSize int
+
+func (s FixedSize) Sampler(*rand.Rand) func() int { return func() int { return int(s) } }
+
+func (s FixedSize) String() string { return fmt.Sprintf("fixed:%d", int(s)) }
+
+// UniformSizes draws sizes uniformly from Min to Max inclusive.
+type UniformSizes struct {
+	Min, Max int
+}
+
+func (s UniformSizes) Sampler(r *rand.Rand) func() int {
+	return func() int { return s.Min + r.Intn(s.Max-s.Min+1) }
+}
+
+func (s UniformSizes) String() string { return fmt.Sprintf("uniform:%d-%d", s.Min, s.Max) }
+
+// ZipfSizes draws sizes from Min to Max inclusive with Zipf's law: Min is the
+// most frequent, and each larger size less frequent than the last. S > 1 is
+// the exponent.
+type ZipfSizes struct {
+	Min, Max int
+	S        float64
+}
+
+func (s ZipfSizes) Sampler(r *rand.Rand) func() int {
+	zipf := rand.NewZipf(r, s.S, 1, uint64(s.Max-s.Min))
+	return func() int { return s.Min + int(zipf.Uint64()) }
+}
+
+func (s ZipfSizes) String() string { return fmt.Sprintf("zipf:%d-%d:%g", s.Min, s.Max, s.S) }
+
+// ParseSizes parses a distribution of payload sizes: SIZE or fixed:SIZE,
+// uniform:MIN-MAX, or zipf:MIN-MAX[:S].
+func ParseSizes(spec string) (Sizes, error) {
+	kind, args, ok := strings.Cut(spec, ":")
+	if !ok {
+		kind, args = "fixed", spec
+	}
+
+	switch kind {
+	case "fixed":
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid payload size %q", args)
+		}
+		return FixedSize(n), nil
+	case "uniform":
+		min, max, err := parseSizeRange(args)
+		if err != nil {
+			return nil, err
+		}
+		return UniformSizes{Min: min, Max: max}, nil
+	case "zipf":
+		sizes, exp, hasExp := strings.Cut(args, ":")
+		min, max, err := parseSizeRange(sizes)
+		if err != nil {
+			return nil, err
+		}
+		s := defaultZipfS
+		if hasExp {
+			if s, err = strconv.ParseFloat(exp, 64); err != nil || s <= 1 {
+				return nil, fmt.Errorf("invalid Zipf exponent %q, must be greater than 1", exp)
+			}
+		}
+		return ZipfSizes{Min: min, Max: max, S: s}, nil
+	default:
+		return nil, fmt.Errorf("unknown payload size distribution %q, expected fixed, uniform or zipf", kind)
+	}
+}
+
+func parseSizeRange(spec string) (int, int, error) {
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid payload size range %q, expected MIN-MAX", spec)
+	}
+	min, err := strconv.Atoi(from)
+	if err != nil || min < 0 {
+		return 0, 0, fmt.Errorf("invalid payload size %q", from)
+	}
+	max, err := strconv.Atoi(to)
+	if err != nil || max < min {
+		return 0, 0, fmt.Errorf("invalid payload size %q, must be at least %d", to, min)
+	}
+	return min, max, nil
+}
+
+// ErrPayloadsExhausted is returned by Payloads that have no more payloads.
+var ErrPayloadsExhausted = errors.New("no more payloads")
+
+// Payloads yields the payloads of the txs of a load test. Implementations are
+// safe for concurrent use.
+type Payloads interface {
+	Next() ([]byte, error)
+}
+
+// describePayloads returns how payloads are made, for reports.
+func describePayloads(p Payloads) string {
+	switch p := p.(type) {
+	case nil:
+		return fmt.Sprintf("random %s", FixedSize(defaultPayloadSize))
+	case fmt.Stringer:
+		return p.String()
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
+
+// randomPayloads are random bytes with sizes drawn from a distribution.
+type randomPayloads struct {
+	sizes Sizes
+	mu    sync.Mutex
+	rand  *rand.Rand
+	size  func() int // draws sizes with rand
+}
+
+// RandomPayloads returns random payloads of the given sizes.
+func RandomPayloads(sizes Sizes) Payloads {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &randomPayloads{sizes: sizes, rand: r, size: sizes.Sampler(r)}
+}
+
+func (p *randomPayloads) String() string { return "random " + p.sizes.String() }
+
+func (p *randomPayloads) Next() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payload := make([]byte, p.size())
+	p.rand.Read(payload)
+	return payload, nil
+}
+
+// replayPayloads goes through the lines of a JSONL file.
+type replayPayloads struct {
+	path  string
+	lines [][]byte
+	cycle bool // whether to start over after the last line
+	next  atomic.Uint64
+}
+
+// ReplayPayloads returns the lines of a JSONL file as payloads, in order.
+// With cycle, it starts over after the last line; otherwise it returns
+// ErrPayloadsExhausted. Raw txs must not cycle, as nodes reject repeated txs
+// as duplicates. Every line must be valid JSON; empty lines are skipped.
+func ReplayPayloads(path string, cycle bool) (Payloads, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer f.Close()
+
+	p := &replayPayloads{path: path, cycle: cycle}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxReplayLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("invalid JSON on line %d of %s", n, path)
+		}
+		p.lines = append(p.lines, bytes.Clone(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replay file: %w", err)
+	}
+	if len(p.lines) == 0 {
+		return nil, fmt.Errorf("replay file %s has no payloads", path)
+	}
+	return p, nil
+}
+
+func (p *replayPayloads) String() string { return "replay " + p.path }
+
+func (p *replayPayloads) Next() ([]byte, error) {
+	i := p.next.Add(1) - 1
+	if !p.cycle && i >= uint64(len(p.lines)) {
+		return nil, fmt.Errorf("%w: replayed all %d lines of %s", ErrPayloadsExhausted, len(p.lines), p.path)
+	}
+	return p.lines[i%uint64(len(p.lines))], nil
+}
+
+// mixedPayloads draws each payload from one of several sources, picked at
+// random by weight.
+type mixedPayloads struct {
+	sources    []Payloads
+	weights    []float64
+	cumulative []float64 // running totals of the weights
+	mu         sync.Mutex
+	rand       *rand.Rand
+}
+
+// MixPayloads returns payloads drawn from sources, each picked with a
+// probability proportional to its weight.
+func MixPayloads(sources []Payloads, weights []float64) (Payloads, error) {
+	if len(sources) == 0 || len(sources) != len(weights) {
+		return nil, fmt.Errorf("a payload mix needs a weight for each of one or more sources")
+	}
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	p := &mixedPayloads{sources: sources, weights: weights, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	var total float64
+	for _, w := range weights {
+		if w <= 0 {
+			return nil, fmt.Errorf("invalid payload weight %g, must be greater than 0", w)
+		}
+		total += w
+		p.cumulative = append(p.cumulative, total)
+	}
+	return p, nil
+}
+
+func (p *mixedPayloads) String() string {
+	parts := make([]string, len(p.sources))
+	for i, source := range p.sources {
+		parts[i] = fmt.Sprintf("%s (weight %g)", describePayloads(source), p.weights[i])
+	}
+	return "mix of " + strings.Join(parts, ", ")
+}
+
+func (p *mixedPayloads) Next() ([]byte, error) {
+	p.mu.Lock()
+	x := p.rand.Float64() * p.cumulative[len(p.cumulative)-1]
+	p.mu.Unlock()
+	for i, c := range p.cumulative {
+		if x < c {
+			return p.sources[i].Next()
+		}
+	}
+	return p.sources[len(p.sources)-1].Next()
+}
@@ -0,0 +1,150 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSizes(t *testing.T) {
+	tests := []struct {
+		spec  string
+		sizes Sizes
+	}{
+		{spec: "256", sizes: FixedSize(256)},
+		{spec: "fixed:0", sizes: FixedSize(0)},
+		{spec: "uniform:64-1024", sizes: UniformSizes{Min: 64, Max: 1024}},
+		{spec: "zipf:64-65536", sizes: ZipfSizes{Min: 64, Max: 65536, S: defaultZipfS}},
+		{spec: "zipf:10-20:2", sizes: ZipfSizes{Min: 10, Max: 20, S: 2}},
+	}
+	for _, tt := range tests {
+		sizes, err := ParseSizes(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.sizes, sizes, tt.spec)
+	}
+
+	for _, spec := range []string{"", "-1", "big", "uniform:10", "uniform:20-10", "zipf:1-10:1", "normal:1-10"} {
+		_, err := ParseSizes(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSizesInRange(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	zipf := ZipfSizes{Min: 100, Max: 200, S: 1.5}.Sampler(r)
+	uniform := UniformSizes{Min: 5, Max: 7}.Sampler(r)
+	counts := make(map[int]int)
+	for i := 0; i < 10000; i++ {
+		n := zipf()
+		require.GreaterOrEqual(t, n, 100)
+		require.LessOrEqual(t, n, 200)
+		counts[n]++
+
+		n = uniform()
+		require.GreaterOrEqual(t, n, 5)
+		require.LessOrEqual(t, n, 7)
+	}
+	assert.Greater(t, counts[100], counts[101])
+	assert.Greater(t, counts[101], counts[110])
+
+	payload, err := RandomPayloads(UniformSizes{Min: 3, Max: 3}).Next()
+	require.NoError(t, err)
+	assert.Len(t, payload, 3)
+}
+
+func TestReplayPayloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"a":1}`+"\n\n"+`{"b":2}`+"\n"), 0o644))
+
+	p, err := ReplayPayloads(path, true)
+	require.NoError(t, err)
+	for _, want := range []string{`{"a":1}`, `{"b":2}`, `{"a":1}`} {
+		payload, err := p.Next()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(payload))
+	}
+
+	// raw txs would repeat, so a replay that doesn't cycle ends with the file
+	p, err = ReplayPayloads(path, false)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = p.Next()
+		require.NoError(t, err)
+	}
+	_, err = p.Next()
+	assert.ErrorIs(t, err, ErrPayloadsExhausted)
+
+	_, err = Run(context.Background(), Config{Nodes: []string{"localhost:1"}, Requests: 3, Concurrency: 1, Payloads: p})
+	assert.ErrorContains(t, err, "fewer than the 3 txs")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"a":1}`+"\nnot json\n"), 0o644))
+	_, err = ReplayPayloads(path, true)
+	assert.ErrorContains(t, err, "line 2")
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0o644))
+	_, err = ReplayPayloads(path, true)
+	assert.Error(t, err)
+}
+
+func TestTxSourceFormats(t *testing.T) {
+	payload := []byte(`{"a":1}`)
+	payloads := RandomPayloads(FixedSize(8))
+
+	raw, err := newTxSource(Config{Payloads: payloads})
+	require.NoError(t, err)
+	tx := takeTx(t, raw)
+	assert.Len(t, tx, 8)
+	assert.False(t, app.IsEnvelope(tx))
+
+	namespaced, err := newTxSource(Config{Payloads: fixedPayload(payload), Format: FormatNamespaced, Namespaces: []string{"x", "y"}})
+	require.NoError(t, err)
+	start := namespaced.nonces.start
+	for nonce := start; nonce < start+3; nonce++ {
+		e, err := app.DecodeEnvelope(takeTx(t, namespaced))
+		require.NoError(t, err)
+		assert.Contains(t, []string{"x", "y"}, e.Namespace)
+		assert.Equal(t, nonce, e.Nonce)
+		assert.Equal(t, payload, []byte(e.Payload))
+		assert.False(t, e.Signed())
+	}
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signed, err := newTxSource(Config{Payloads: fixedPayload(payload), Format: FormatSigned, Keys: []*ecdsa.PrivateKey{key}})
+	require.NoError(t, err)
+	e, err := app.DecodeEnvelope(takeTx(t, signed))
+	require.NoError(t, err)
+	assert.Equal(t, defaultNamespace, e.Namespace)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), e.Producer)
+	assert.True(t, e.Signed())
+	assert.NoError(t, e.Verify())
+
+	for _, s := range []string{"raw", "namespaced", "signed"} {
+		f, err := ParseFormat(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, f.String())
+	}
+	_, err = ParseFormat("json")
+	assert.Error(t, err)
+}
+
+func takeTx(t *testing.T, s *txSource) []byte {
+	t.Helper()
+	txs, release, err := s.take(context.Background(), 1)
+	require.NoError(t, err)
+	release()
+	return txs[0]
+}
+
+// fixedPayload always yields the same payload.
+type fixedPayload []byte
+
+func (p fixedPayload) Next() ([]byte, error) { return p, nil }
@@ -0,0 +1,112 @@
+package load
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProducerOrder is how the signed txs of one producer came out of the streams.
+type ProducerOrder struct {
+	Producer   common.Address `json:"producer"`
+	Sequenced  uint64         `json:"sequenced"`         // txs in the first stream
+	OutOfOrder uint64         `json:"out_of_order"`      // txs, in any stream, whose nonce is not above the one before
+	Example    string         `json:"example,omitempty"` // the first tx out of order
+}
+
+// lastNonce is the nonce of the latest tx of a producer in a stream.
+type lastNonce struct {
+	seen  bool
+	nonce uint64
+}
+
+// nonceOrder checks that each producer's txs arrive in every stream in nonce
+// order. Nonces may be skipped, by txs that were not accepted. It is not safe
+// for concurrent use.
+type nonceOrder struct {
+	streams   []string
+	producers map[common.Address]*ProducerOrder
+	last      map[common.Address][]lastNonce
+}
+
+func newNonceOrder(streams []string, producers []common.Address) *nonceOrder {
+	o := &nonceOrder{
+		streams:   streams,
+		producers: make(map[common.Address]*ProducerOrder),
+		last:      make(map[common.Address][]lastNonce),
+	}
+	for _, p := range producers {
+		o.producers[p] = &ProducerOrder{Producer: p}
+		o.last[p] = make([]lastNonce, len(streams))
+	}
+	return o
+}
+
+// arrive records a tx of producer with nonce arriving in stream i. Txs of
+// other producers are ignored.
+func (o *nonceOrder) arrive(i int, producer common.Address, nonce uint64) {
+	p, ok := o.producers[producer]
+	if !ok {
+		return
+	}
+	if i == 0 {
+		p.Sequenced++
+	}
+
+	last := &o.last[producer][i]
+	if last.seen && nonce <= last.nonce {
+		p.OutOfOrder++
+		if p.Example == "" {
+			p.Example = fmt.Sprintf("nonce %d after %d in %s", nonce, last.nonce, o.streams[i])
+		}
+		return
+	}
+	last.seen, last.nonce = true, nonce
+}
+
+// report returns the order of each producer's txs, by address.
+func (o *nonceOrder) report() []ProducerOrder {
+	var producers []ProducerOrder
+	for _, p := range o.producers {
+		producers = append(producers, *p)
+	}
+	sort.Slice(producers, func(i, j int) bool {
+		return bytes.Compare(producers[i].Producer[:], producers[j].Producer[:]) < 0
+	})
+	return producers
+}
+
+// LoadKeystore decrypts the keys of a keystore directory with password.
+func LoadKeystore(dir, password string) ([]*ecdsa.PrivateKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var keys []*ecdsa.PrivateKey
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		key, err := keystore.DecryptKey(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+		keys = append(keys, key.PrivateKey)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in keystore %s", dir)
+	}
+	return keys, nil
+}
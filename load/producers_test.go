@@ -0,0 +1,166 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxSourceProducerNonces(t *testing.T) {
+	s, err := newTxSource(Config{Payloads: RandomPayloads(FixedSize(4)), Format: FormatSigned, Producers: 3})
+	require.NoError(t, err)
+	require.Len(t, s.addresses, 3)
+
+	var mu sync.Mutex
+	nonces := make(map[common.Address][]uint64)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				txs, release, err := s.take(context.Background(), 2)
+				require.NoError(t, err)
+				mu.Lock()
+				for _, tx := range txs {
+					e, err := app.DecodeEnvelope(tx)
+					require.NoError(t, err)
+					require.NoError(t, e.Verify())
+					nonces[e.Producer] = append(nonces[e.Producer], e.Nonce)
+				}
+				mu.Unlock()
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a producer is leased to one taker at a time, so its nonces are taken in order
+	total := 0
+	for _, p := range s.addresses {
+		for i, n := range nonces[p] {
+			assert.Equal(t, s.nonces.start+uint64(i), n)
+		}
+		total += len(nonces[p])
+	}
+	assert.Equal(t, 200, total)
+}
+
+func TestNoncesStartAtCreation(t *testing.T) {
+	before := uint64(time.Now().UnixNano())
+	first := NewNonces()
+	addr := common.Address{1}
+	assert.GreaterOrEqual(t, *first.producer(addr), before)
+	*first.producer(addr) += 1000
+
+	// a later run with the same key continues above the nonces of an earlier
+	// one that sent fewer txs than nanoseconds passed
+	time.Sleep(time.Millisecond)
+	second := NewNonces()
+	assert.Greater(t, *second.producer(addr), *first.producer(addr))
+	assert.Greater(t, second.envelope.Load(), first.envelope.Load())
+}
+
+func TestTakeWaitsForFreeProducer(t *testing.T) {
+	s, err := newTxSource(Config{Format: FormatSigned})
+	require.NoError(t, err)
+	_, release, err := s.take(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = s.take(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	_, _, err = s.take(context.Background(), 1)
+	assert.NoError(t, err)
+}
+
+func TestNonceOrder(t *testing.T) {
+	p1, p2, other := common.Address{1}, common.Address{2}, common.Address{9}
+	o := newNonceOrder([]string{"a", "b"}, []common.Address{p2, p1})
+	for _, n := range []uint64{0, 1, 3} {
+		o.arrive(0, p1, n)
+		o.arrive(1, p1, n)
+	}
+	o.arrive(0, p2, 1)
+	o.arrive(0, p2, 0)
+	o.arrive(0, other, 5)
+	o.arrive(0, other, 4)
+
+	report := o.report()
+	require.Len(t, report, 2)
+	assert.Equal(t, ProducerOrder{Producer: p1, Sequenced: 3}, report[0])
+	assert.Equal(t, ProducerOrder{Producer: p2, Sequenced: 2, OutOfOrder: 1, Example: "nonce 0 after 1 in a"}, report[1])
+}
+
+func TestLoadKeystore(t *testing.T) {
+	dir := t.TempDir()
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	var want []common.Address
+	for i := 0; i < 2; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		account, err := ks.ImportECDSA(key, "secret")
+		require.NoError(t, err)
+		want = append(want, account.Address)
+	}
+
+	keys, err := LoadKeystore(dir, "secret")
+	require.NoError(t, err)
+	var got []common.Address
+	for _, key := range keys {
+		got = append(got, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	assert.ElementsMatch(t, want, got)
+
+	_, err = LoadKeystore(dir, "wrong")
+	assert.Error(t, err)
+	_, err = LoadKeystore(filepath.Join(dir, "missing"), "secret")
+	assert.Error(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0o755))
+	_, err = LoadKeystore(filepath.Join(dir, "empty"), "secret")
+	assert.Error(t, err)
+}
+
+func TestRunChecksProducerOrder(t *testing.T) {
+	ds := streamtest.NewServer(t)
+	node := sequencingNode(t, []*streamtest.Server{ds}, []time.Duration{0})
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node},
+		Requests:    30,
+		Concurrency: 6,
+		Streams:     []string{ds.Addr},
+		StreamWait:  10 * time.Second,
+		Format:      FormatSigned,
+		Keys:        []*ecdsa.PrivateKey{key},
+		Producers:   3,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Producers, 3)
+	var sequenced uint64
+	for _, p := range report.Producers {
+		sequenced += p.Sequenced
+		assert.Zero(t, p.OutOfOrder)
+	}
+	assert.Equal(t, uint64(30), sequenced)
+	require.NotNil(t, report.InNonceOrder)
+	assert.True(t, *report.InNonceOrder)
+}
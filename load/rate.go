@@ -0,0 +1,303 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateTolerance is how far below its target a stage may be and still count as held.
+const rateTolerance = 0.05
+
+// maxRate is the highest target rate, one tx per nanosecond, which the schedule
+// can still space out.
+const maxRate = float64(time.Second)
+
+// Stage is a period of an open-loop test during which the target rate changes
+// linearly from From to To txs per second.
+type Stage struct {
+	From     float64       `json:"from"`
+	To       float64       `json:"to"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Target is the average rate of the stage.
+func (s Stage) Target() float64 {
+	return (s.From + s.To) / 2
+}
+
+// rate returns the target rate at elapsed into the stage.
+func (s Stage) rate(elapsed time.Duration) float64 {
+	if s.Duration <= 0 {
+		return s.To
+	}
+	return s.From + (s.To-s.From)*float64(elapsed)/float64(s.Duration)
+}
+
+// next returns how long after at the next tx is due: the time over which the
+// target rate adds up to one tx. It returns false if the rate drops to zero first.
+func (s Stage) next(at time.Duration) (time.Duration, bool) {
+	r := s.rate(at)
+	slope := (s.To - s.From) / s.Duration.Seconds()
+
+	var dt float64
+	if slope == 0 {
+		if r <= 0 {
+			return 0, false
+		}
+		dt = 1 / r
+	} else {
+		// solve r*dt + slope*dt²/2 = 1
+		d := r*r + 2*slope
+		if d < 0 {
+			return 0, false
+		}
+		dt = (math.Sqrt(d) - r) / slope
+	}
+	// a tx due within the same nanosecond is scheduled a nanosecond later, so
+	// the schedule always moves on
+	return max(time.Duration(dt*float64(time.Second)), 1), true
+}
+
+func (s Stage) String() string {
+	if s.From == s.To {
+		return fmt.Sprintf("%g tx/s for %s", s.To, s.Duration)
+	}
+	return fmt.Sprintf("%g to %g tx/s over %s", s.From, s.To, s.Duration)
+}
+
+// ParseStages parses comma separated stages of the form RATE:DURATION, for a
+// constant rate, or FROM-TO:DURATION, for a linear ramp. For example
+// "0-100:30s,100:5m" ramps up to 100 tx/s over 30 seconds and holds it for 5 minutes.
+func ParseStages(spec string) ([]Stage, error) {
+	var stages []Stage
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		rates, duration, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid stage %q, expected RATE:DURATION or FROM-TO:DURATION", part)
+		}
+		var s Stage
+		var err error
+		if s.From, s.To, err = parseRates(rates); err != nil {
+			return nil, fmt.Errorf("%w in stage %q", err, part)
+		}
+		if s.Duration, err = time.ParseDuration(duration); err != nil || s.Duration <= 0 {
+			return nil, fmt.Errorf("invalid duration %q in stage %q", duration, part)
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
+
+// parseRates parses the rates of a stage: RATE, for a constant rate, or
+// FROM-TO, for a linear ramp.
+func parseRates(rates string) (from, to float64, err error) {
+	fromSpec, toSpec, ramp := strings.Cut(rates, "-")
+	if !ramp {
+		toSpec = fromSpec
+	}
+	if from, err = strconv.ParseFloat(fromSpec, 64); err != nil || from < 0 || from > maxRate {
+		return 0, 0, fmt.Errorf("invalid rate %q", fromSpec)
+	}
+	if to, err = strconv.ParseFloat(toSpec, 64); err != nil || to < 0 || to > maxRate {
+		return 0, 0, fmt.Errorf("invalid rate %q", toSpec)
+	}
+	return from, to, nil
+}
+
+// RateStages returns the stages of a test at a constant rate for duration,
+// preceded by a linear ramp from zero if rampUp is set.
+func RateStages(rate float64, duration, rampUp time.Duration) []Stage {
+	var stages []Stage
+	if rampUp > 0 {
+		stages = append(stages, Stage{From: 0, To: rate, Duration: rampUp})
+	}
+	return append(stages, Stage{From: rate, To: rate, Duration: duration})
+}
+
+// StageReport is the result of one stage of an open-loop test.
+type StageReport struct {
+	Stage
+	Scheduled uint64  `json:"scheduled"` // txs the schedule called for
+	Achieved  float64 `json:"achieved"`  // committed txs per second
+	Held      bool    `json:"held"`      // whether Achieved was within tolerance of the target
+	SendLag   float64 `json:"send_lag_p99_ms"`
+	Summary
+}
+
+// job is a tx the schedule calls for.
+type job struct {
+	stage    int
+	intended time.Time
+}
+
+// stageStats collects the results of the txs scheduled in a stage.
+type stageStats struct {
+	stats     *Stats
+	lag       histogram
+	scheduled uint64
+	last      time.Time // completion of the last committed tx
+}
+
+// runStages runs an open-loop test.
+func runStages(ctx context.Context, cfg Config, rn *runner) (*Report, error) {
+	stats := NewStats()
+	start := time.Now()
+	stages, err := runOpen(ctx, cfg, rn, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	report := stats.Report(time.Since(start))
+	report.Stages = stages
+	held := true
+	for _, s := range stages {
+		held = held && s.Held
+	}
+	report.Held = &held
+	return report, nil
+}
+
+// runOpen sends txs at the rates of cfg.Stages regardless of how fast the nodes
+// respond. Latencies are measured from when each tx was due to be sent, not
+// from when it was sent, so a backlog of sends shows up in the latencies
+// instead of silently lowering the rate (coordinated omission).
+func runOpen(ctx context.Context, cfg Config, rn *runner, stats *Stats) ([]StageReport, error) {
+	stages := make([]*stageStats, len(cfg.Stages))
+	for i := range stages {
+		stages[i] = &stageStats{stats: NewStats(), lag: newHistogram()}
+	}
+
+	jobs := make(chan job, 1<<16)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.MaxInFlight; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				// txs that are due together go in one request
+				batch := takeJobs(jobs, j, cfg.batch())
+				sent := time.Now()
+				results := rn.send(ctx, len(batch))
+				done := time.Now()
+
+				for i, r := range results {
+					if ctx.Err() != nil && r.Outcome != OK {
+						continue
+					}
+					j := batch[i]
+					r.Latency = done.Sub(j.intended)
+
+					stats.Add(r)
+					s := stages[j.stage]
+					s.stats.Add(r)
+					mu.Lock()
+					s.lag.record(sent.Sub(j.intended))
+					if r.Outcome == OK && done.After(s.last) {
+						s.last = done
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	schedule(ctx, cfg.Stages, start, func(j job) {
+		mu.Lock()
+		stages[j.stage].scheduled++
+		mu.Unlock()
+		jobs <- j
+	})
+	close(jobs)
+	wg.Wait()
+
+	reports := make([]StageReport, len(stages))
+	stageStart := start
+	for i, s := range stages {
+		stage := cfg.Stages[i]
+		r := StageReport{
+			Stage:     stage,
+			Scheduled: s.scheduled,
+			SendLag:   s.lag.latency().P99,
+			Summary:   s.stats.Report(stage.Duration).Total,
+		}
+
+		// the txs of a stage complete about a typical latency after they are
+		// sent, so that much time past the end of the stage is not counted
+		span := stage.Duration
+		if !s.last.IsZero() {
+			latency := time.Duration(r.Latency.P50 * float64(time.Millisecond))
+			span = max(span, s.last.Sub(stageStart)-latency)
+		}
+		r.Achieved = float64(r.OK) / span.Seconds()
+		r.Held = r.Achieved >= stage.Target()*(1-rateTolerance)
+		reports[i] = r
+
+		stageStart = stageStart.Add(stage.Duration)
+	}
+	return reports, nil
+}
+
+// takeJobs returns first and up to max-1 more jobs that are already queued.
+func takeJobs(jobs <-chan job, first job, max int) []job {
+	batch := []job{first}
+	for len(batch) < max {
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, j)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// schedule calls fn at the time each tx of the stages is due, until the last
+// stage ends or ctx is done.
+func schedule(ctx context.Context, stages []Stage, start time.Time, fn func(job)) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	stageStart := start
+	for i, stage := range stages {
+		at, ok := time.Duration(0), true
+		if stage.rate(0) <= 0 {
+			at, ok = stage.next(0)
+		}
+		for ok && at < stage.Duration {
+			due := stageStart.Add(at)
+			timer.Reset(time.Until(due))
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			fn(job{stage: i, intended: due})
+
+			var dt time.Duration
+			dt, ok = stage.next(at)
+			at += dt
+		}
+		stageStart = stageStart.Add(stage.Duration)
+	}
+	// wait for the end of the last stage, even if no tx is due before it
+	timer.Reset(time.Until(stageStart))
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,109 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStages(t *testing.T) {
+	stages, err := ParseStages("0-100:30s, 100:5m,50-0:1m")
+	require.NoError(t, err)
+	assert.Equal(t, []Stage{
+		{From: 0, To: 100, Duration: 30 * time.Second},
+		{From: 100, To: 100, Duration: 5 * time.Minute},
+		{From: 50, To: 0, Duration: time.Minute},
+	}, stages)
+
+	for _, spec := range []string{"", "100", "x:1s", "1-x:1s", "-5:1s", "100:0s", "100:soon", "2e9:1s", "0-2e9:1s"} {
+		_, err := ParseStages(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestStageNextMovesOn(t *testing.T) {
+	dt, ok := Stage{From: maxRate, To: maxRate, Duration: time.Second}.next(0)
+	require.True(t, ok)
+	assert.Equal(t, time.Nanosecond, dt)
+
+	// the rate rounds the interval down to nothing
+	dt, ok = Stage{From: 0, To: 1e12, Duration: time.Millisecond}.next(time.Millisecond)
+	require.True(t, ok)
+	assert.Equal(t, time.Nanosecond, dt)
+}
+
+func TestScheduleFollowsRamp(t *testing.T) {
+	stages := []Stage{
+		{From: 0, To: 400, Duration: 250 * time.Millisecond},
+		{From: 400, To: 0, Duration: 250 * time.Millisecond},
+	}
+	start := time.Now()
+	var jobs []job
+	schedule(context.Background(), stages, start, func(j job) { jobs = append(jobs, j) })
+
+	// each ramp adds up to 400 * 0.25 / 2 = 50 txs
+	counts := make([]int, len(stages))
+	last := start
+	for _, j := range jobs {
+		counts[j.stage]++
+		assert.False(t, j.intended.Before(last))
+		last = j.intended
+	}
+	assert.InDelta(t, 50, counts[0], 1)
+	assert.InDelta(t, 50, counts[1], 1)
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRunStagesHoldsRate(t *testing.T) {
+	node, requests := fakeNode(t, http.StatusOK, okResponse)
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node},
+		Stages:      RateStages(200, 500*time.Millisecond, 0),
+		MaxInFlight: 10,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Stages, 1)
+	s := report.Stages[0]
+	assert.InDelta(t, 100, s.Scheduled, 1)
+	assert.Equal(t, s.Scheduled, s.OK)
+	assert.Equal(t, int64(s.Scheduled), requests.Load())
+	assert.True(t, s.Held, "achieved %.1f tx/s", s.Achieved)
+	require.NotNil(t, report.Held)
+	assert.True(t, *report.Held)
+
+	var out strings.Builder
+	require.NoError(t, report.Print(&out))
+	assert.Contains(t, out.String(), "Target rate held: yes")
+}
+
+func TestRunStagesCorrectsForCoordinatedOmission(t *testing.T) {
+	// a node taking 50ms per tx, one at a time, manages 20 tx/s
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, okResponse)
+	}))
+	t.Cleanup(srv.Close)
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{strings.TrimPrefix(srv.URL, "http://")},
+		Stages:      RateStages(100, 300*time.Millisecond, 0),
+		MaxInFlight: 1,
+	})
+	require.NoError(t, err)
+
+	s := report.Stages[0]
+	assert.False(t, s.Held, "achieved %.1f tx/s", s.Achieved)
+	assert.False(t, *report.Held)
+	// txs queue behind each other, which the latencies include
+	assert.Greater(t, s.Latency.Max, 500.0)
+	assert.Greater(t, s.SendLag, 400.0)
+}
@@ -0,0 +1,175 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Print writes the report in a human-readable form.
+func (r *Report) Print(w io.Writer) error {
+	t := r.Total
+	fmt.Fprintf(w, "Load test results:\n")
+	fmt.Fprintf(w, "  Requests:    %d\n", t.Requests)
+	ok := "accepted"
+	if r.Mode == ModeCommit.String() {
+		ok = "committed"
+		fmt.Fprintf(w, "  Committed:   %d\n", t.OK)
+	} else {
+		fmt.Fprintf(w, "  Accepted:    %d (%s)\n", t.OK, r.Mode)
+	}
+	fmt.Fprintf(w, "  Errors:      %d HTTP, %d RPC\n", t.HTTPErrors, t.RPCErrors)
+	fmt.Fprintf(w, "  Rejected:    %d by CheckTx, %d in block\n", t.CheckTxRejected, t.TxRejected)
+	fmt.Fprintf(w, "  Elapsed:     %s\n", r.Elapsed.Round(1e6))
+	fmt.Fprintf(w, "  Throughput:  %.1f %s tx/s\n", t.Throughput, ok)
+	fmt.Fprintf(w, "  Latency:     p50 %.1f ms, p90 %.1f ms, p99 %.1f ms, max %.1f ms, mean %.1f ms\n",
+		t.Latency.P50, t.Latency.P90, t.Latency.P99, t.Latency.Max, t.Latency.Mean)
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "node\trequests\tok\thttp err\trpc err\tcheck_tx rej\ttx rej\ttx/s\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+	for _, n := range r.Nodes {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			n.Node, n.Requests, n.OK, n.HTTPErrors, n.RPCErrors, n.CheckTxRejected, n.TxRejected,
+			n.Throughput, n.Latency.P50, n.Latency.P90, n.Latency.P99, n.Latency.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Stages) > 0 {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "stage\ttarget tx/s\tscheduled\tok\tachieved tx/s\theld\tp50 ms\tp99 ms\tsend lag p99 ms\t")
+		for _, s := range r.Stages {
+			fmt.Fprintf(tw, "%s\t%.1f\t%d\t%d\t%.1f\t%s\t%.1f\t%.1f\t%.1f\t\n",
+				s.Stage, s.Target(), s.Scheduled, s.OK, s.Achieved, yesNo(s.Held),
+				s.Latency.P50, s.Latency.P99, s.SendLag)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(r.Streams) > 0 {
+		fmt.Fprintf(w, "\nSubmit-to-stream latency:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "stream\tseen\tmissing\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+		for _, s := range r.Streams {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+				s.Stream, s.Seen, s.Missing, s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.Max)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if r.Skew != nil {
+		fmt.Fprintf(w, "Arrival skew across streams: p50 %.1f ms, p90 %.1f ms, p99 %.1f ms, max %.1f ms\n",
+			r.Skew.P50, r.Skew.P90, r.Skew.P99, r.Skew.Max)
+	}
+	if r.Audit != nil {
+		if err := r.Audit.print(w); err != nil {
+			return err
+		}
+	}
+	if len(r.Producers) > 0 {
+		fmt.Fprintf(w, "\nProducers:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "producer\tsequenced\tout of order\t")
+		for _, p := range r.Producers {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t\n", p.Producer, p.Sequenced, p.OutOfOrder)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, p := range r.Producers {
+			if p.Example != "" {
+				fmt.Fprintf(w, "  %s: %s\n", p.Producer, p.Example)
+			}
+		}
+	}
+	if r.InNonceOrder != nil {
+		fmt.Fprintf(w, "Producer txs in nonce order: %s\n", yesNo(*r.InNonceOrder))
+	}
+	if r.Held != nil {
+		fmt.Fprintf(w, "\nTarget rate held: %s\n", yesNo(*r.Held))
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(w, "\nMost frequent errors:\n")
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "  %6d  %s\n", e.Count, e.Error)
+		}
+	}
+	return nil
+}
+
+// Print writes the phase's report and the thresholds it missed.
+func (p *PhaseReport) Print(w io.Writer) error {
+	fmt.Fprintf(w, "=== Phase %s ===\n", p.Name)
+	if err := p.Report.Print(w); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nThresholds met: %s\n", yesNo(p.Passed))
+	for _, f := range p.Failures {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+	return nil
+}
+
+// Print writes a summary of the scenario's phases.
+func (r *ScenarioReport) Print(w io.Writer) error {
+	if r.Name != "" {
+		fmt.Fprintf(w, "Scenario %s:\n", r.Name)
+	} else {
+		fmt.Fprintf(w, "Scenario:\n")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "phase\trequests\tok\ttx/s\tp50 ms\tp99 ms\tpassed\t")
+	for _, p := range r.Phases {
+		t := p.Report.Total
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%s\t\n",
+			p.Name, t.Requests, t.OK, t.Throughput, t.Latency.P50, t.Latency.P99, yesNo(p.Passed))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Scenario passed: %s\n", yesNo(r.Passed))
+	return nil
+}
+
+func (a *Audit) print(w io.Writer) error {
+	fmt.Fprintf(w, "\nAudit of %d submitted txs, %d accepted:\n", a.Submitted, a.Accepted)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "stream\tsequenced\tmissing\tduplicated\tunexpected\t")
+	for _, s := range a.Streams {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\n", s.Stream, s.Sequenced, s.Missing, s.Duplicated, s.Unexpected)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, s := range a.Streams {
+		for _, h := range s.MissingTxs {
+			fmt.Fprintf(w, "  missing from %s: %s\n", s.Stream, h)
+		}
+		for _, h := range s.Duplicates {
+			fmt.Fprintf(w, "  duplicated in %s: %s\n", s.Stream, h)
+		}
+	}
+	if d := a.Divergence; d != nil {
+		fmt.Fprintf(w, "  streams order the txs differently from tx %d:\n", d.Position)
+		for i, s := range a.Streams {
+			fmt.Fprintf(w, "    %s: %s\n", s.Stream, d.Txs[i])
+		}
+	} else {
+		fmt.Fprintf(w, "  all streams have the same order\n")
+	}
+	fmt.Fprintf(w, "Audit passed: %s\n", yesNo(a.Passed))
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,384 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultMaxInFlight bounds the txs in flight of a phase unless it sets max_in_flight.
+	defaultMaxInFlight = 1000
+
+	// defaultStreamWait is how long a phase waits for its txs to reach the streams
+	// unless it sets stream_wait.
+	defaultStreamWait = 10 * time.Second
+)
+
+// Scenario is a load test in phases, such as a warmup, a steady load, a spike
+// and a soak, run one after another.
+type Scenario struct {
+	Name   string
+	Phases []Phase
+}
+
+// Phase is one phase of a scenario: an open-loop test and the thresholds its
+// report must meet.
+type Phase struct {
+	Name       string
+	Config     Config
+	Thresholds Thresholds
+}
+
+// Thresholds are the limits a phase passes or fails on. Zero values are not checked.
+type Thresholds struct {
+	// most latency of OK txs at each percentile
+	P50 time.Duration `yaml:"p50"`
+	P90 time.Duration `yaml:"p90"`
+	P99 time.Duration `yaml:"p99"`
+	Max time.Duration `yaml:"max"`
+
+	ErrorRate *float64      `yaml:"error_rate"` // most txs not OK, as a fraction of those sent
+	HoldRate  bool          `yaml:"hold_rate"`  // whether every stage must hold its target rate
+	StreamP99 time.Duration `yaml:"stream_p99"` // most p99 submit-to-stream latency on every stream
+}
+
+// Check returns how report misses the thresholds, if it does. A failed audit
+// or producer txs out of nonce order also miss them.
+func (t Thresholds) Check(report *Report) []string {
+	var failures []string
+	latency := func(name string, got float64, limit time.Duration) {
+		if limit > 0 && got > float64(limit)/float64(time.Millisecond) {
+			failures = append(failures, fmt.Sprintf("%s latency %.1f ms above %s", name, got, limit))
+		}
+	}
+	latency("p50", report.Total.Latency.P50, t.P50)
+	latency("p90", report.Total.Latency.P90, t.P90)
+	latency("p99", report.Total.Latency.P99, t.P99)
+	latency("max", report.Total.Latency.Max, t.Max)
+	for _, s := range report.Streams {
+		latency("p99 submit-to-stream "+s.Stream, s.Latency.P99, t.StreamP99)
+	}
+
+	if t.ErrorRate != nil && report.Total.Requests > 0 {
+		failed := report.Total.Requests - report.Total.OK
+		if rate := float64(failed) / float64(report.Total.Requests); rate > *t.ErrorRate {
+			failures = append(failures, fmt.Sprintf("error rate %.4f above %g (%d of %d txs)",
+				rate, *t.ErrorRate, failed, report.Total.Requests))
+		}
+	}
+	if t.HoldRate && report.Held != nil && !*report.Held {
+		failures = append(failures, "target rate not held")
+	}
+	if report.Audit != nil && !report.Audit.Passed {
+		failures = append(failures, "audit failed")
+	}
+	if report.InNonceOrder != nil && !*report.InNonceOrder {
+		failures = append(failures, "producer txs out of nonce order")
+	}
+	return failures
+}
+
+// PhaseReport is the result of one phase of a scenario.
+type PhaseReport struct {
+	Name     string   `json:"name"`
+	Report   *Report  `json:"report"`
+	Failures []string `json:"failures,omitempty"` // thresholds missed
+	Passed   bool     `json:"passed"`
+}
+
+// ScenarioReport is the result of a scenario.
+type ScenarioReport struct {
+	Name   string        `json:"name,omitempty"`
+	Phases []PhaseReport `json:"phases"`
+	Passed bool          `json:"passed"` // whether every phase ran and passed
+}
+
+// RunScenario runs the phases of s in order, calling done with each phase's
+// report as it ends. The phases continue each other's nonces. Cancelling ctx ends the current phase and skips the rest,
+// which fails the scenario.
+func RunScenario(ctx context.Context, s *Scenario, done func(PhaseReport)) (*ScenarioReport, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	result := &ScenarioReport{Name: s.Name, Passed: true}
+	nonces := NewNonces()
+	for _, p := range s.Phases {
+		if ctx.Err() != nil {
+			result.Passed = false
+			break
+		}
+		if p.Config.Nonces == nil {
+			p.Config.Nonces = nonces
+		}
+		report, err := Run(ctx, p.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run phase %s: %w", p.Name, err)
+		}
+		pr := PhaseReport{Name: p.Name, Report: report, Failures: p.Thresholds.Check(report)}
+		pr.Passed = len(pr.Failures) == 0
+		result.Passed = result.Passed && pr.Passed
+		result.Phases = append(result.Phases, pr)
+		if done != nil {
+			done(pr)
+		}
+	}
+	if ctx.Err() != nil {
+		result.Passed = false
+	}
+	return result, nil
+}
+
+// validate checks every phase before any of them runs.
+func (s *Scenario) validate() error {
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("scenario has no phases")
+	}
+	for _, p := range s.Phases {
+		if len(p.Config.Stages) == 0 {
+			return fmt.Errorf("phase %s has no rate", p.Name)
+		}
+		if err := p.Config.validate(); err != nil {
+			return fmt.Errorf("invalid phase %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// scenarioFile is the YAML form of a scenario.
+type scenarioFile struct {
+	Name          string `yaml:"name"`
+	phaseSettings `yaml:",inline"`
+	Phases        []phaseFile `yaml:"phases"`
+}
+
+// phaseFile is the YAML form of a phase.
+type phaseFile struct {
+	Name          string        `yaml:"name"`
+	Rate          string        `yaml:"rate"` // RATE, or FROM-TO for a ramp
+	Duration      time.Duration `yaml:"duration"`
+	Stages        string        `yaml:"stages"` // as for ParseStages, instead of rate and duration
+	Thresholds    Thresholds    `yaml:"thresholds"`
+	phaseSettings `yaml:",inline"`
+}
+
+// phaseSettings are the settings of a phase, which a scenario sets for all of
+// its phases and a phase may override.
+type phaseSettings struct {
+	Nodes       []string      `yaml:"nodes"`
+	Streams     []string      `yaml:"streams"`
+	StreamWait  time.Duration `yaml:"stream_wait"`
+	Audit       *bool         `yaml:"audit"`
+	Payloads    []payloadFile `yaml:"payloads"`
+	Format      string        `yaml:"format"`
+	Namespaces  []string      `yaml:"namespaces"`
+	Producers   int           `yaml:"producers"`
+	Mode        string        `yaml:"mode"`
+	Transport   string        `yaml:"transport"`
+	Batch       int           `yaml:"batch"`
+	MaxInFlight int           `yaml:"max_in_flight"`
+}
+
+// payloadFile is one source of a payload mix: random payloads with sizes as
+// for ParseSizes, or the lines of a replay file.
+type payloadFile struct {
+	Size   string  `yaml:"size"`
+	Replay string  `yaml:"replay"`
+	Weight float64 `yaml:"weight"` // relative to the other sources, 1 if unset
+}
+
+// override returns s with the settings o sets.
+func (s phaseSettings) override(o phaseSettings) phaseSettings {
+	if len(o.Nodes) > 0 {
+		s.Nodes = o.Nodes
+	}
+	if len(o.Streams) > 0 {
+		s.Streams = o.Streams
+	}
+	if o.StreamWait > 0 {
+		s.StreamWait = o.StreamWait
+	}
+	if o.Audit != nil {
+		s.Audit = o.Audit
+	}
+	if len(o.Payloads) > 0 {
+		s.Payloads = o.Payloads
+	}
+	if o.Format != "" {
+		s.Format = o.Format
+	}
+	if len(o.Namespaces) > 0 {
+		s.Namespaces = o.Namespaces
+	}
+	if o.Producers > 0 {
+		s.Producers = o.Producers
+	}
+	if o.Mode != "" {
+		s.Mode = o.Mode
+	}
+	if o.Transport != "" {
+		s.Transport = o.Transport
+	}
+	if o.Batch > 0 {
+		s.Batch = o.Batch
+	}
+	if o.MaxInFlight > 0 {
+		s.MaxInFlight = o.MaxInFlight
+	}
+	return s
+}
+
+// LoadScenario reads a scenario from a YAML file. Replay files are relative to
+// the scenario file's directory.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	var f scenarioFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if len(f.Phases) == 0 {
+		return nil, fmt.Errorf("scenario %s has no phases", path)
+	}
+
+	s := &Scenario{Name: f.Name}
+	replays := make(map[replayKey]Payloads)
+	for i, pf := range f.Phases {
+		if pf.Name == "" {
+			pf.Name = fmt.Sprintf("phase %d", i+1)
+		}
+		p, err := pf.phase(f.phaseSettings.override(pf.phaseSettings), filepath.Dir(path), replays)
+		if err != nil {
+			return nil, fmt.Errorf("invalid phase %s: %w", pf.Name, err)
+		}
+		s.Phases = append(s.Phases, p)
+	}
+	return s, nil
+}
+
+// replayKey identifies a replay shared by the phases of a scenario.
+type replayKey struct {
+	path  string
+	cycle bool
+}
+
+// phase returns the phase f describes with settings s. Its replays continue
+// where earlier phases' replays of the same files stopped.
+func (f phaseFile) phase(s phaseSettings, dir string, replays map[replayKey]Payloads) (Phase, error) {
+	p := Phase{Name: f.Name, Thresholds: f.Thresholds}
+	cfg := &p.Config
+
+	var err error
+	switch {
+	case f.Stages != "" && f.Rate != "":
+		return p, fmt.Errorf("only one of rate or stages can be set")
+	case f.Stages != "":
+		if cfg.Stages, err = ParseStages(f.Stages); err != nil {
+			return p, err
+		}
+	case f.Rate != "":
+		if f.Duration <= 0 {
+			return p, fmt.Errorf("a duration is required with a rate")
+		}
+		from, to, err := parseRates(f.Rate)
+		if err != nil {
+			return p, err
+		}
+		cfg.Stages = []Stage{{From: from, To: to, Duration: f.Duration}}
+	default:
+		return p, fmt.Errorf("a rate or stages are required")
+	}
+
+	if s.Format != "" {
+		if cfg.Format, err = ParseFormat(s.Format); err != nil {
+			return p, err
+		}
+	}
+	if cfg.Payloads, err = s.payloads(dir, cfg.Format != FormatRaw, replays); err != nil {
+		return p, err
+	}
+	if s.Mode != "" {
+		if cfg.Mode, err = ParseMode(s.Mode); err != nil {
+			return p, err
+		}
+	}
+	if s.Transport != "" {
+		if cfg.Transport, err = ParseTransport(s.Transport); err != nil {
+			return p, err
+		}
+	}
+	cfg.Nodes = s.Nodes
+	cfg.Streams = s.Streams
+	cfg.StreamWait = s.StreamWait
+	if cfg.StreamWait == 0 {
+		cfg.StreamWait = defaultStreamWait
+	}
+	cfg.Audit = s.Audit != nil && *s.Audit
+	cfg.Namespaces = s.Namespaces
+	cfg.Producers = s.Producers
+	cfg.Batch = s.Batch
+	cfg.MaxInFlight = s.MaxInFlight
+	if cfg.MaxInFlight == 0 {
+		cfg.MaxInFlight = defaultMaxInFlight
+	}
+	return p, nil
+}
+
+// payloads returns the payload mix of s, or nil for the default payloads.
+// Replay files cycle if cycleReplays is set, and are taken from replays if
+// earlier phases replay them too.
+func (s phaseSettings) payloads(dir string, cycleReplays bool, replays map[replayKey]Payloads) (Payloads, error) {
+	if len(s.Payloads) == 0 {
+		return nil, nil
+	}
+	var sources []Payloads
+	var weights []float64
+	for _, pf := range s.Payloads {
+		var source Payloads
+		switch {
+		case pf.Size != "" && pf.Replay != "":
+			return nil, fmt.Errorf("only one of size or replay can be set for a payload")
+		case pf.Replay != "":
+			path := pf.Replay
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			key := replayKey{path: path, cycle: cycleReplays}
+			if replays[key] == nil {
+				replay, err := ReplayPayloads(path, cycleReplays)
+				if err != nil {
+					return nil, err
+				}
+				replays[key] = replay
+			}
+			source = replays[key]
+		default:
+			spec := pf.Size
+			if spec == "" {
+				spec = fmt.Sprint(defaultPayloadSize)
+			}
+			sizes, err := ParseSizes(spec)
+			if err != nil {
+				return nil, err
+			}
+			source = RandomPayloads(sizes)
+		}
+		weight := pf.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		sources = append(sources, source)
+		weights = append(weights, weight)
+	}
+	return MixPayloads(sources, weights)
+}
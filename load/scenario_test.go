@@ -0,0 +1,260 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScenario(t *testing.T, dir, yaml string) string {
+	t.Helper()
+	path := filepath.Join(dir, "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yaml), 0o644))
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orders.jsonl"), []byte(`{"id":1}`+"\n"), 0o644))
+	path := writeScenario(t, dir, `
+name: nightly
+nodes: [a:26657, b:26657]
+format: namespaced
+namespaces: [orders]
+phases:
+  - name: warmup
+    rate: 0-100
+    duration: 30s
+  - name: spike
+    rate: 500
+    duration: 1m
+    nodes: [c:26657]
+    mode: sync
+    transport: post
+    batch: 10
+    payloads:
+      - size: uniform:64-1024
+        weight: 3
+      - replay: orders.jsonl
+    thresholds:
+      p99: 250ms
+      error_rate: 0.01
+      hold_rate: true
+  - stages: 100:1m,50:1m
+`)
+
+	s, err := LoadScenario(path)
+	require.NoError(t, err)
+	assert.Equal(t, "nightly", s.Name)
+	require.Len(t, s.Phases, 3)
+
+	warmup := s.Phases[0]
+	assert.Equal(t, "warmup", warmup.Name)
+	assert.Equal(t, []Stage{{From: 0, To: 100, Duration: 30 * time.Second}}, warmup.Config.Stages)
+	assert.Equal(t, []string{"a:26657", "b:26657"}, warmup.Config.Nodes)
+	assert.Equal(t, FormatNamespaced, warmup.Config.Format)
+	assert.Equal(t, []string{"orders"}, warmup.Config.Namespaces)
+	assert.Equal(t, ModeCommit, warmup.Config.Mode)
+	assert.Equal(t, defaultMaxInFlight, warmup.Config.MaxInFlight)
+	assert.Nil(t, warmup.Config.Payloads)
+	assert.Equal(t, Thresholds{}, warmup.Thresholds)
+
+	spike := s.Phases[1]
+	assert.Equal(t, []string{"c:26657"}, spike.Config.Nodes)
+	assert.Equal(t, FormatNamespaced, spike.Config.Format)
+	assert.Equal(t, ModeSync, spike.Config.Mode)
+	assert.Equal(t, TransportPost, spike.Config.Transport)
+	assert.Equal(t, 10, spike.Config.Batch)
+	assert.IsType(t, &mixedPayloads{}, spike.Config.Payloads)
+	assert.Equal(t, 250*time.Millisecond, spike.Thresholds.P99)
+	require.NotNil(t, spike.Thresholds.ErrorRate)
+	assert.Equal(t, 0.01, *spike.Thresholds.ErrorRate)
+	assert.True(t, spike.Thresholds.HoldRate)
+
+	assert.Equal(t, "phase 3", s.Phases[2].Name)
+	assert.Len(t, s.Phases[2].Config.Stages, 2)
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"no phases":        "nodes: [a:1]\n",
+		"no rate":          "phases:\n  - duration: 1m\n",
+		"no duration":      "phases:\n  - rate: 10\n",
+		"rate and stages":  "phases:\n  - rate: 10\n    duration: 1m\n    stages: 10:1m\n",
+		"bad rate":         "phases:\n  - rate: fast\n    duration: 1m\n",
+		"bad format":       "format: json\nphases:\n  - rate: 10\n    duration: 1m\n",
+		"bad payload":      "phases:\n  - rate: 10\n    duration: 1m\n    payloads: [{size: normal:1-2}]\n",
+		"missing replay":   "phases:\n  - rate: 10\n    duration: 1m\n    payloads: [{replay: missing.jsonl}]\n",
+		"unknown field":    "phases:\n  - rate: 10\n    duration: 1m\n    thresholds: {p95: 1s}\n",
+		"negative weight":  "phases:\n  - rate: 10\n    duration: 1m\n    payloads: [{size: '8', weight: -1}, {size: '9'}]\n",
+		"invalid duration": "phases:\n  - rate: 10\n    duration: soon\n",
+	} {
+		_, err := LoadScenario(writeScenario(t, t.TempDir(), yaml))
+		assert.Error(t, err, name)
+	}
+	_, err := LoadScenario(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	no := false
+	errorRate := 0.1
+	report := &Report{
+		Total: Summary{Requests: 100, OK: 80, Latency: Latency{P50: 10, P90: 40, P99: 120, Max: 300}},
+		Held:  &no,
+		Streams: []StreamSummary{
+			{Stream: "s1", Latency: Latency{P99: 90}},
+			{Stream: "s2", Latency: Latency{P99: 600}},
+		},
+		Audit:        &Audit{Passed: false},
+		InNonceOrder: &no,
+	}
+
+	assert.Empty(t, Thresholds{P50: 20 * time.Millisecond, Max: time.Second}.Check(&Report{}))
+	failures := Thresholds{
+		P50:       20 * time.Millisecond,
+		P99:       100 * time.Millisecond,
+		Max:       time.Second,
+		ErrorRate: &errorRate,
+		HoldRate:  true,
+		StreamP99: 500 * time.Millisecond,
+	}.Check(report)
+	assert.Equal(t, []string{
+		"p99 latency 120.0 ms above 100ms",
+		"p99 submit-to-stream s2 latency 600.0 ms above 500ms",
+		"error rate 0.2000 above 0.1 (20 of 100 txs)",
+		"target rate not held",
+		"audit failed",
+		"producer txs out of nonce order",
+	}, failures)
+}
+
+func TestMixPayloads(t *testing.T) {
+	p, err := MixPayloads([]Payloads{fixedPayload("a"), fixedPayload("b")}, []float64{3, 1})
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		payload, err := p.Next()
+		require.NoError(t, err)
+		counts[string(payload)]++
+	}
+	assert.InDelta(t, 3000, counts["a"], 200)
+	assert.InDelta(t, 1000, counts["b"], 200)
+
+	_, err = MixPayloads(nil, nil)
+	assert.Error(t, err)
+	_, err = MixPayloads([]Payloads{fixedPayload("a"), fixedPayload("b")}, []float64{1, 0})
+	assert.Error(t, err)
+}
+
+func TestRunScenario(t *testing.T) {
+	ok, _ := fakeNode(t, http.StatusOK, okResponse)
+	rejecting, _ := fakeNode(t, http.StatusOK, checkTxResponse)
+	errorRate := 0.05
+	s := &Scenario{Phases: []Phase{
+		{
+			Name:       "steady",
+			Config:     Config{Nodes: []string{ok}, Stages: RateStages(100, 200*time.Millisecond, 0), MaxInFlight: 10},
+			Thresholds: Thresholds{P99: time.Second, ErrorRate: &errorRate},
+		},
+		{
+			Name:       "rejected",
+			Config:     Config{Nodes: []string{rejecting}, Stages: RateStages(100, 200*time.Millisecond, 0), MaxInFlight: 10},
+			Thresholds: Thresholds{ErrorRate: &errorRate},
+		},
+	}}
+
+	var done []string
+	report, err := RunScenario(context.Background(), s, func(p PhaseReport) { done = append(done, p.Name) })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"steady", "rejected"}, done)
+	require.Len(t, report.Phases, 2)
+	assert.True(t, report.Phases[0].Passed, report.Phases[0].Failures)
+	assert.False(t, report.Phases[1].Passed)
+	assert.Contains(t, report.Phases[1].Failures[0], "error rate 1.0000")
+	assert.False(t, report.Passed)
+
+	var out strings.Builder
+	require.NoError(t, report.Phases[1].Print(&out))
+	require.NoError(t, report.Print(&out))
+	assert.Contains(t, out.String(), "=== Phase rejected ===")
+	assert.Contains(t, out.String(), "Thresholds met: no\n  error rate 1.0000 above 0.05")
+	assert.Contains(t, out.String(), "Scenario passed: no")
+
+	// every phase is checked before any runs
+	s.Phases[1].Config.Nodes = nil
+	_, err = RunScenario(context.Background(), s, nil)
+	assert.ErrorContains(t, err, "rejected")
+	assert.Equal(t, []string{"steady", "rejected"}, done)
+}
+
+func TestRunScenarioContinuesNonces(t *testing.T) {
+	// the node rejects a tx it has seen before, as the mempool cache does
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var duplicates atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx := r.URL.Query().Get("tx")
+		mu.Lock()
+		dup := seen[tx]
+		seen[tx] = true
+		mu.Unlock()
+		if dup {
+			duplicates.Add(1)
+			fmt.Fprint(w, rpcErrorResponse)
+			return
+		}
+		fmt.Fprint(w, okResponse)
+	}))
+	t.Cleanup(srv.Close)
+	node := strings.TrimPrefix(srv.URL, "http://")
+
+	dir := t.TempDir()
+	// enveloped txs differ only by nonce, and raw txs by line
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "order.jsonl"), []byte(`{"id":1}`+"\n"), 0o644))
+	var lines strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&lines, `{"id":%d}`+"\n", i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orders.jsonl"), []byte(lines.String()), 0o644))
+	path := writeScenario(t, dir, `
+nodes: [`+node+`]
+payloads:
+  - replay: order.jsonl
+phases:
+  - {name: namespaced 1, format: namespaced, rate: 50, duration: 100ms}
+  - {name: namespaced 2, format: namespaced, rate: 50, duration: 100ms}
+  - {name: signed 1, format: signed, rate: 50, duration: 100ms}
+  - {name: signed 2, format: signed, rate: 50, duration: 100ms}
+  - {name: raw 1, rate: 50, duration: 100ms, payloads: [{replay: orders.jsonl}]}
+  - {name: raw 2, rate: 50, duration: 100ms, payloads: [{replay: orders.jsonl}]}
+`)
+	s, err := LoadScenario(path)
+	require.NoError(t, err)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	for i := range s.Phases {
+		s.Phases[i].Config.Keys = []*ecdsa.PrivateKey{key}
+	}
+
+	report, err := RunScenario(context.Background(), s, nil)
+	require.NoError(t, err)
+	for _, p := range report.Phases {
+		assert.Positive(t, p.Report.Total.OK, p.Name)
+		assert.Zero(t, p.Report.Total.RPCErrors, p.Name)
+	}
+	assert.Zero(t, duplicates.Load())
+}
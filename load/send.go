@@ -0,0 +1,259 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtjson "github.com/cometbft/cometbft/libs/json"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+)
+
+// Outcome classifies the result of submitting a tx.
+type Outcome int
+
+const (
+	OK              Outcome = iota // accepted as far as the mode requires
+	HTTPError                      // the request failed or got no JSON-RPC response
+	RPCError                       // the node answered with a JSON-RPC error
+	CheckTxRejected                // CheckTx returned a non-zero code
+	TxRejected                     // the tx was committed with a non-zero code
+
+	numOutcomes = iota
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OK:
+		return "ok"
+	case HTTPError:
+		return "http_error"
+	case RPCError:
+		return "rpc_error"
+	case CheckTxRejected:
+		return "check_tx_rejected"
+	case TxRejected:
+		return "tx_rejected"
+	default:
+		return fmt.Sprintf("outcome_%d", int(o))
+	}
+}
+
+// Mode is the broadcast_tx method txs are sent with, which decides how far a
+// tx has to get before its submission completes.
+type Mode int
+
+const (
+	ModeCommit Mode = iota // wait for the tx to be committed in a block
+	ModeSync               // wait for CheckTx
+	ModeAsync              // return as soon as the node received the tx
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeCommit:
+		return "commit"
+	case ModeSync:
+		return "sync"
+	case ModeAsync:
+		return "async"
+	default:
+		return fmt.Sprintf("mode_%d", int(m))
+	}
+}
+
+func (m Mode) method() string {
+	return "broadcast_tx_" + m.String()
+}
+
+// ParseMode parses a broadcast mode: sync, async or commit.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range []Mode{ModeCommit, ModeSync, ModeAsync} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown broadcast mode %q, expected sync, async or commit", s)
+}
+
+// Transport is how requests reach the nodes' CometBFT RPC.
+type Transport int
+
+const (
+	TransportGet       Transport = iota // URI requests, with the tx in the query string
+	TransportPost                       // JSON-RPC POST requests, batched if there are several txs
+	TransportWebSocket                  // JSON-RPC requests over WebSocket connections
+)
+
+func (t Transport) String() string {
+	switch t {
+	case TransportGet:
+		return "get"
+	case TransportPost:
+		return "post"
+	case TransportWebSocket:
+		return "ws"
+	default:
+		return fmt.Sprintf("transport_%d", int(t))
+	}
+}
+
+// ParseTransport parses a transport: get, post or ws.
+func ParseTransport(s string) (Transport, error) {
+	for _, t := range []Transport{TransportGet, TransportPost, TransportWebSocket} {
+		if s == t.String() {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown transport %q, expected get, post or ws", s)
+}
+
+// Result is the outcome of submitting one tx to a node.
+type Result struct {
+	Node    string
+	Latency time.Duration
+	Outcome Outcome
+	Code    uint32 // ABCI code of a rejected tx
+	Err     error  // what went wrong, unless the outcome is OK
+}
+
+// caller makes JSON-RPC calls with one tx each to a node, and returns the
+// responses in the order of the txs.
+type caller interface {
+	call(ctx context.Context, node, method string, txs [][]byte) ([]rpctypes.RPCResponse, error)
+	close() error
+}
+
+// Sender submits txs to nodes over their CometBFT RPC.
+type Sender struct {
+	mode      Mode
+	transport Transport
+	caller    caller
+}
+
+// SenderOption configures a Sender.
+type SenderOption func(*Sender) error
+
+// WithMode sets the broadcast mode, commit by default.
+func WithMode(mode Mode) SenderOption {
+	return func(s *Sender) error {
+		s.mode = mode
+		return nil
+	}
+}
+
+// WithTransport sets the transport, GET requests by default.
+func WithTransport(transport Transport) SenderOption {
+	return func(s *Sender) error {
+		s.transport = transport
+		return nil
+	}
+}
+
+// NewSender returns a sender keeping up to conns idle connections per node.
+func NewSender(conns int, opts ...SenderOption) (*Sender, error) {
+	s := &Sender{}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
+	switch s.transport {
+	case TransportGet:
+		s.caller = &getCaller{client: newHTTPClient(conns)}
+	case TransportPost:
+		s.caller = &postCaller{client: newHTTPClient(conns)}
+	case TransportWebSocket:
+		s.caller = newWSCaller(conns)
+	default:
+		return nil, fmt.Errorf("unknown transport %s", s.transport)
+	}
+	return s, nil
+}
+
+// Close closes the sender's connections.
+func (s *Sender) Close() error {
+	return s.caller.close()
+}
+
+// Send submits tx to node and classifies the response.
+func (s *Sender) Send(ctx context.Context, node string, tx []byte) Result {
+	return s.SendBatch(ctx, node, [][]byte{tx})[0]
+}
+
+// SendBatch submits txs to node together, in a single batch request with the
+// POST transport, and classifies the response to each. The latency of every
+// tx is that of the whole batch.
+func (s *Sender) SendBatch(ctx context.Context, node string, txs [][]byte) []Result {
+	start := time.Now()
+	responses, err := s.caller.call(ctx, node, s.mode.method(), txs)
+	latency := time.Since(start)
+
+	results := make([]Result, len(txs))
+	for i := range results {
+		r := Result{Node: node, Latency: latency}
+		var rpcErr *rpctypes.RPCError
+		switch {
+		case errors.As(err, &rpcErr):
+			r.Outcome, r.Err = RPCError, err
+		case err != nil:
+			r.Outcome, r.Err = HTTPError, err
+		default:
+			s.classify(&r, responses[i])
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// classify sets the outcome of r from the response to its broadcast request.
+func (s *Sender) classify(r *Result, resp rpctypes.RPCResponse) {
+	if resp.Error != nil {
+		r.Outcome, r.Err = RPCError, resp.Error
+		return
+	}
+
+	var res coretypes.ResultBroadcastTxCommit
+	var err error
+	if s.mode == ModeCommit {
+		err = decodeResult(resp, &res)
+	} else {
+		// the sync and async results are those of CheckTx
+		var sync coretypes.ResultBroadcastTx
+		err = decodeResult(resp, &sync)
+		res.CheckTx = abci.ResponseCheckTx{Code: sync.Code, Log: sync.Log}
+	}
+
+	switch {
+	case err != nil:
+		r.Outcome, r.Err = HTTPError, err
+	case res.CheckTx.Code != abci.CodeTypeOK:
+		r.Outcome, r.Code = CheckTxRejected, res.CheckTx.Code
+		r.Err = fmt.Errorf("CheckTx code %d: %s", res.CheckTx.Code, res.CheckTx.Log)
+	case res.TxResult.Code != abci.CodeTypeOK:
+		r.Outcome, r.Code = TxRejected, res.TxResult.Code
+		r.Err = fmt.Errorf("tx result code %d: %s", res.TxResult.Code, res.TxResult.Log)
+	}
+}
+
+// decodeResponse parses a JSON-RPC response.
+func decodeResponse(body []byte) (rpctypes.RPCResponse, error) {
+	var resp rpctypes.RPCResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return resp, fmt.Errorf("invalid JSON-RPC response: %w", err)
+	}
+	return resp, nil
+}
+
+// decodeResult parses the result of a successful response into res.
+func decodeResult(resp rpctypes.RPCResponse, res interface{}) error {
+	if err := cmtjson.Unmarshal(resp.Result, res); err != nil {
+		return fmt.Errorf("invalid broadcast result: %w", err)
+	}
+	return nil
+}
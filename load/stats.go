@@ -0,0 +1,241 @@
+package load
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// latencies are recorded in microseconds, from 1µs to 5 minutes
+	minLatency     = 1
+	maxLatency     = int64(5 * time.Minute / time.Microsecond)
+	significantFig = 3
+
+	maxErrorKinds = 20 // distinct error messages kept for the report
+)
+
+// Latency summarizes a latency distribution, in milliseconds.
+type Latency struct {
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+	Mean float64 `json:"mean_ms"`
+}
+
+// Summary counts the outcomes of the txs sent to a node, or to all of them.
+type Summary struct {
+	Requests        uint64  `json:"requests"`
+	OK              uint64  `json:"ok"` // committed in commit mode, accepted by CheckTx otherwise
+	HTTPErrors      uint64  `json:"http_errors"`
+	RPCErrors       uint64  `json:"rpc_errors"`
+	CheckTxRejected uint64  `json:"check_tx_rejected"`
+	TxRejected      uint64  `json:"tx_rejected"`
+	Throughput      float64 `json:"throughput"` // OK txs per second
+	Latency         Latency `json:"latency"`    // of OK txs
+}
+
+// histogram records latencies in microseconds.
+type histogram struct {
+	*hdrhistogram.Histogram
+}
+
+func newHistogram() histogram {
+	return histogram{hdrhistogram.New(minLatency, maxLatency, significantFig)}
+}
+
+func (h histogram) record(d time.Duration) {
+	_ = h.RecordValue(min(max(d.Microseconds(), minLatency), maxLatency))
+}
+
+func (h histogram) latency() Latency {
+	if h.TotalCount() == 0 {
+		return Latency{}
+	}
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	return Latency{
+		P50:  ms(h.ValueAtQuantile(50)),
+		P90:  ms(h.ValueAtQuantile(90)),
+		P99:  ms(h.ValueAtQuantile(99)),
+		Max:  ms(h.Max()),
+		Mean: h.Mean() / 1000,
+	}
+}
+
+// histogram returns the distribution of the latencies, or nil if none were recorded.
+func (h histogram) histogram() *Histogram {
+	if h.TotalCount() == 0 {
+		return nil
+	}
+	out := &Histogram{
+		Count:   uint64(h.TotalCount()),
+		Sum:     h.Mean() * float64(h.TotalCount()) / 1000,
+		Buckets: make([]Bucket, len(bucketBounds)),
+	}
+	for i, le := range bucketBounds {
+		out.Buckets[i].LE = le
+	}
+	for _, bar := range h.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		ms := float64(bar.From) / 1000
+		for i := range out.Buckets {
+			if ms <= out.Buckets[i].LE {
+				out.Buckets[i].Count += uint64(bar.Count)
+			}
+		}
+	}
+	return out
+}
+
+// bucketBounds are the upper bounds, in milliseconds, of the buckets of a
+// latency histogram. The last is maxLatency, which longer latencies are
+// recorded as, so it counts every latency.
+var bucketBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000, 60000, float64(maxLatency) / 1000}
+
+// Bucket counts the latencies up to an upper bound, including those of the
+// buckets below it.
+type Bucket struct {
+	LE    float64 `json:"le_ms"`
+	Count uint64  `json:"count"`
+}
+
+// Histogram is the distribution of a latency in cumulative buckets.
+type Histogram struct {
+	Buckets []Bucket `json:"buckets"`
+	Count   uint64   `json:"count"`
+	Sum     float64  `json:"sum_ms"`
+}
+
+// counter accumulates the results of one node, or of all nodes.
+type counter struct {
+	outcomes [numOutcomes]uint64
+	latency  histogram
+}
+
+func newCounter() *counter {
+	return &counter{latency: newHistogram()}
+}
+
+func (c *counter) add(r Result) {
+	c.outcomes[r.Outcome]++
+	if r.Outcome == OK {
+		c.latency.record(r.Latency)
+	}
+}
+
+func (c *counter) summary(elapsed time.Duration) Summary {
+	s := Summary{
+		OK:              c.outcomes[OK],
+		HTTPErrors:      c.outcomes[HTTPError],
+		RPCErrors:       c.outcomes[RPCError],
+		CheckTxRejected: c.outcomes[CheckTxRejected],
+		TxRejected:      c.outcomes[TxRejected],
+		Latency:         c.latency.latency(),
+	}
+	for _, n := range c.outcomes {
+		s.Requests += n
+	}
+	if elapsed > 0 {
+		s.Throughput = float64(s.OK) / elapsed.Seconds()
+	}
+	return s
+}
+
+// Stats collects the results of a load test. It is safe for concurrent use.
+type Stats struct {
+	mu     sync.Mutex
+	total  *counter
+	nodes  map[string]*counter
+	errors map[string]uint64
+}
+
+// NewStats returns empty stats.
+func NewStats() *Stats {
+	return &Stats{
+		total:  newCounter(),
+		nodes:  make(map[string]*counter),
+		errors: make(map[string]uint64),
+	}
+}
+
+// Add records a result.
+func (s *Stats) Add(r Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total.add(r)
+	node, ok := s.nodes[r.Node]
+	if !ok {
+		node = newCounter()
+		s.nodes[r.Node] = node
+	}
+	node.add(r)
+
+	if r.Err != nil {
+		msg := r.Outcome.String() + ": " + r.Err.Error()
+		if _, ok := s.errors[msg]; ok || len(s.errors) < maxErrorKinds {
+			s.errors[msg]++
+		}
+	}
+}
+
+// NodeSummary is the summary of the txs sent to one node.
+type NodeSummary struct {
+	Node string `json:"node"`
+	Summary
+}
+
+// ErrorCount is how often an error message was seen.
+type ErrorCount struct {
+	Error string `json:"error"`
+	Count uint64 `json:"count"`
+}
+
+// Report is the result of a load test.
+type Report struct {
+	Config    *RunConfig    `json:"config,omitempty"`
+	Mode      string        `json:"mode"` // broadcast mode, which decides what OK means
+	Elapsed   time.Duration `json:"elapsed"`
+	Total     Summary       `json:"total"`
+	Histogram *Histogram    `json:"histogram,omitempty"` // of the latency of OK txs
+	Nodes     []NodeSummary `json:"nodes"`
+	Errors    []ErrorCount  `json:"errors,omitempty"` // most frequent first
+
+	Stages []StageReport `json:"stages,omitempty"` // of an open-loop test
+	Held   *bool         `json:"held,omitempty"`   // whether every stage held its target rate
+
+	Streams []StreamSummary `json:"streams,omitempty"`      // of the nodes whose streams were followed
+	Skew    *Latency        `json:"arrival_skew,omitempty"` // between the first and last stream a tx reached
+	Audit   *Audit          `json:"audit,omitempty"`
+
+	Producers    []ProducerOrder `json:"producers,omitempty"`      // of signed txs, from the streams
+	InNonceOrder *bool           `json:"in_nonce_order,omitempty"` // whether every producer's txs were in nonce order
+}
+
+// Report summarizes the results recorded over elapsed.
+func (s *Stats) Report(elapsed time.Duration) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &Report{Elapsed: elapsed, Total: s.total.summary(elapsed), Histogram: s.total.latency.histogram()}
+	for node, c := range s.nodes {
+		r.Nodes = append(r.Nodes, NodeSummary{Node: node, Summary: c.summary(elapsed)})
+	}
+	sort.Slice(r.Nodes, func(i, j int) bool { return r.Nodes[i].Node < r.Nodes[j].Node })
+
+	for msg, n := range s.errors {
+		r.Errors = append(r.Errors, ErrorCount{Error: msg, Count: n})
+	}
+	sort.Slice(r.Errors, func(i, j int) bool {
+		if r.Errors[i].Count != r.Errors[j].Count {
+			return r.Errors[i].Count > r.Errors[j].Count
+		}
+		return r.Errors[i].Error < r.Errors[j].Error
+	})
+	return r
+}
@@ -0,0 +1,269 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pollInterval is how often the tracker checks whether every committed tx has
+// reached the streams.
+const pollInterval = 10 * time.Millisecond
+
+// StreamSummary is how the txs of a load test reached one node's stream.
+type StreamSummary struct {
+	Stream  string  `json:"stream"`
+	Seen    uint64  `json:"seen"`    // submitted txs seen in the stream
+	Missing uint64  `json:"missing"` // committed txs not seen in time
+	Latency Latency `json:"latency"` // from submitting a tx to its EtL2Tx entry arriving
+
+	Histogram *Histogram `json:"histogram,omitempty"` // of Latency
+}
+
+// trackedTx is a submitted tx and when it arrived in each stream.
+type trackedTx struct {
+	submitted time.Time
+	arrived   []time.Time // zero until seen
+	seen      int
+	done      bool // the submission completed
+	committed bool
+}
+
+// tracker follows the streams of the nodes under load and matches the submitted
+// txs to their entries. A nil tracker tracks nothing.
+type tracker struct {
+	streams []string
+
+	mu      sync.Mutex
+	txs     map[common.Hash]*trackedTx
+	pending int // committed txs not seen in every stream yet
+	seen    []uint64
+	latency []histogram
+	skew    histogram
+	audit   *audit      // nil unless auditing
+	order   *nonceOrder // nil unless txs are signed
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	errs   []error
+}
+
+// newTracker subscribes to the streams from their current end, or returns nil
+// if there are none. With audit set it also records every tx for an audit, and
+// with producers it checks the order of their signed txs.
+func newTracker(ctx context.Context, streams []string, audit bool, producers []common.Address) (*tracker, error) {
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	t := &tracker{
+		streams: streams,
+		txs:     make(map[common.Hash]*trackedTx),
+		seen:    make([]uint64, len(streams)),
+		latency: make([]histogram, len(streams)),
+		skew:    newHistogram(),
+		errs:    make([]error, len(streams)),
+	}
+	if audit {
+		t.audit = newAudit(len(streams))
+	}
+	if len(producers) > 0 {
+		t.order = newNonceOrder(streams, producers)
+	}
+
+	clients := make([]*client.Client, len(streams))
+	for i, addr := range streams {
+		c, err := client.New(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stream client for %s: %w", addr, err)
+		}
+		header, err := c.Header(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream header of %s: %w", addr, err)
+		}
+		if clients[i], err = client.New(addr, client.WithFromEntry(header.TotalEntries)); err != nil {
+			return nil, fmt.Errorf("failed to create stream client for %s: %w", addr, err)
+		}
+		t.latency[i] = newHistogram()
+	}
+
+	ctx, t.cancel = context.WithCancel(ctx)
+	for i, c := range clients {
+		t.wg.Add(1)
+		go func(i int, c *client.Client) {
+			defer t.wg.Done()
+			err := c.RunBlocks(ctx, func(b *client.Block) error {
+				now := time.Now()
+				for _, tx := range b.Txs {
+					t.arrive(i, tx, now)
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, context.Canceled) {
+				t.errs[i] = err
+			}
+		}(i, c)
+	}
+	return t, nil
+}
+
+// submit registers tx just before it is sent.
+func (t *tracker) submit(tx []byte) {
+	if t == nil {
+		return
+	}
+	hash := app.TxHash(tx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.txs[hash] = &trackedTx{submitted: time.Now(), arrived: make([]time.Time, len(t.streams))}
+	if t.audit != nil {
+		t.audit.submit(hash)
+	}
+}
+
+// done records whether the submission of tx was committed. Txs that failed
+// and have not been seen are forgotten.
+func (t *tracker) done(tx []byte, committed bool) {
+	if t == nil {
+		return
+	}
+	hash := app.TxHash(tx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.audit != nil && committed {
+		t.audit.accept(hash)
+	}
+	tt, ok := t.txs[hash]
+	if !ok {
+		return
+	}
+	tt.done, tt.committed = true, committed
+	switch {
+	case tt.seen == len(t.streams):
+		delete(t.txs, hash)
+	case committed:
+		t.pending++
+	case tt.seen == 0:
+		delete(t.txs, hash)
+	}
+}
+
+// arrive records tx arriving in stream i. Apart from the audit, txs that were
+// not submitted by this test, and repeated arrivals, are ignored.
+func (t *tracker) arrive(i int, tx client.Tx, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hash := tx.Hash
+	if t.order != nil && tx.Envelope != nil && tx.Envelope.Signed() {
+		t.order.arrive(i, tx.Envelope.Producer, tx.Envelope.Nonce)
+	}
+	if t.audit != nil {
+		t.audit.arrive(i, hash)
+	}
+	tt, ok := t.txs[hash]
+	if !ok || !tt.arrived[i].IsZero() {
+		return
+	}
+	tt.arrived[i] = at
+	tt.seen++
+	t.seen[i]++
+	t.latency[i].record(at.Sub(tt.submitted))
+
+	if tt.seen < len(t.streams) {
+		return
+	}
+	first, last := tt.arrived[0], tt.arrived[0]
+	for _, a := range tt.arrived[1:] {
+		first, last = minTime(first, a), maxTime(last, a)
+	}
+	t.skew.record(last.Sub(first))
+
+	if tt.done {
+		if tt.committed {
+			t.pending--
+		}
+		delete(t.txs, hash)
+	}
+}
+
+// finish waits up to wait for the committed txs to arrive in every stream,
+// stops following the streams and summarizes them. It returns the arrival
+// skew across streams if there is more than one.
+func (t *tracker) finish(ctx context.Context, wait time.Duration) ([]StreamSummary, *Latency, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+wait:
+	for {
+		t.mu.Lock()
+		pending := t.pending
+		t.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-deadline.C:
+			break wait
+		case <-ticker.C:
+		}
+	}
+	t.cancel()
+	t.wg.Wait()
+
+	for i, err := range t.errs {
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to follow stream of %s: %w", t.streams[i], err)
+		}
+	}
+
+	summaries := make([]StreamSummary, len(t.streams))
+	for i, s := range t.streams {
+		summaries[i] = StreamSummary{
+			Stream:    s,
+			Seen:      t.seen[i],
+			Latency:   t.latency[i].latency(),
+			Histogram: t.latency[i].histogram(),
+		}
+	}
+	for _, tt := range t.txs {
+		if !tt.committed {
+			continue
+		}
+		for i, a := range tt.arrived {
+			if a.IsZero() {
+				summaries[i].Missing++
+			}
+		}
+	}
+
+	if len(t.streams) < 2 {
+		return summaries, nil, nil
+	}
+	skew := t.skew.latency()
+	return summaries, &skew, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
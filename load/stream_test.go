@@ -0,0 +1,93 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app/testutil/streamtest"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequencingNode commits every tx it receives in a block of its own, written to
+// each of the streams after the matching delay.
+func sequencingNode(t *testing.T, streams []*streamtest.Server, delays []time.Duration) string {
+	t.Helper()
+	var mu sync.Mutex
+	var height uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx, err := hexutil.Decode(r.URL.Query().Get("tx"))
+		require.NoError(t, err)
+
+		// one block at a time, so that every stream has the same order
+		mu.Lock()
+		defer mu.Unlock()
+		height++
+		var wg sync.WaitGroup
+		for i, ds := range streams {
+			wg.Add(1)
+			go func(ds *streamtest.Server, delay time.Duration) {
+				defer wg.Done()
+				time.Sleep(delay)
+				ds.AddBlock(height, height, tx)
+			}(ds, delays[i])
+		}
+		wg.Wait()
+		fmt.Fprint(w, okResponse)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestRunMatchesStreams(t *testing.T) {
+	fast, slow := streamtest.NewServer(t), streamtest.NewServer(t)
+	// entries from before the test are not matched
+	fast.AddBlock(1000, 1000, []byte("earlier"))
+
+	node := sequencingNode(t, []*streamtest.Server{fast, slow}, []time.Duration{0, 20 * time.Millisecond})
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node},
+		Requests:    20,
+		Concurrency: 1,
+		Streams:     []string{fast.Addr, slow.Addr},
+		StreamWait:  10 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), report.Total.OK)
+
+	require.Len(t, report.Streams, 2)
+	for _, s := range report.Streams {
+		assert.Equal(t, uint64(20), s.Seen, s.Stream)
+		assert.Zero(t, s.Missing, s.Stream)
+	}
+	assert.Greater(t, report.Streams[1].Latency.P50, report.Streams[0].Latency.P50)
+	require.NotNil(t, report.Skew)
+	assert.GreaterOrEqual(t, report.Skew.P50, 15.0)
+}
+
+func TestRunReportsMissingFromStream(t *testing.T) {
+	addr := streamtest.NewServer(t).Addr
+	node, _ := fakeNode(t, http.StatusOK, okResponse)
+
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node},
+		Requests:    5,
+		Concurrency: 1,
+		Streams:     []string{addr},
+		StreamWait:  100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Streams, 1)
+	assert.Zero(t, report.Streams[0].Seen)
+	assert.Equal(t, uint64(5), report.Streams[0].Missing)
+	assert.Nil(t, report.Skew)
+}
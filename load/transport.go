@@ -0,0 +1,266 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+)
+
+// newHTTPClient returns a client keeping up to conns idle connections per
+// host, so that connections are reused rather than redialed under load.
+func newHTTPClient(conns int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 0
+	transport.MaxIdleConnsPerHost = conns
+	return &http.Client{Transport: transport}
+}
+
+// do sends req and returns the body. Non-200 responses are returned too if
+// they are JSON, since CometBFT reports JSON-RPC errors with HTTP error statuses.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && !json.Valid(body) {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// getCaller makes URI requests, one per tx.
+type getCaller struct {
+	client *http.Client
+}
+
+func (c *getCaller) call(ctx context.Context, node, method string, txs [][]byte) ([]rpctypes.RPCResponse, error) {
+	responses := make([]rpctypes.RPCResponse, len(txs))
+	for i, tx := range txs {
+		url := fmt.Sprintf("http://%s/%s?tx=%s", node, method, hexutil.Encode(tx))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := do(c.client, req)
+		if err != nil {
+			return nil, err
+		}
+		if responses[i], err = decodeResponse(body); err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+func (c *getCaller) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// postCaller makes JSON-RPC POST requests, batching the calls for several txs.
+type postCaller struct {
+	client *http.Client
+	ids    atomic.Int64
+}
+
+func (c *postCaller) call(ctx context.Context, node, method string, txs [][]byte) ([]rpctypes.RPCResponse, error) {
+	requests, err := newRequests(&c.ids, method, txs)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if len(requests) == 1 {
+		body, err = json.Marshal(requests[0])
+	} else {
+		body, err = json.Marshal(requests)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+node, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body, err = do(c.client, req); err != nil {
+		return nil, err
+	}
+
+	var responses []rpctypes.RPCResponse
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return nil, fmt.Errorf("invalid JSON-RPC batch response: %w", err)
+		}
+	} else {
+		resp, err := decodeResponse(body)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, resp)
+	}
+	return inOrder(requests, responses)
+}
+
+func (c *postCaller) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// wsCaller makes JSON-RPC requests over WebSocket connections. CometBFT handles
+// the requests of a connection one at a time, so each call takes a connection
+// of its own, which is kept for later calls like an idle HTTP connection.
+type wsCaller struct {
+	dialer  websocket.Dialer
+	maxIdle int
+	ids     atomic.Int64
+
+	mu     sync.Mutex
+	idle   map[string][]*websocket.Conn
+	closed bool
+}
+
+func newWSCaller(conns int) *wsCaller {
+	return &wsCaller{dialer: *websocket.DefaultDialer, maxIdle: conns, idle: make(map[string][]*websocket.Conn)}
+}
+
+func (c *wsCaller) call(ctx context.Context, node, method string, txs [][]byte) ([]rpctypes.RPCResponse, error) {
+	requests, err := newRequests(&c.ids, method, txs)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.conn(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	// closing the connection unblocks it if ctx is done first
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	responses, err := roundTrip(conn, requests)
+	if stop() && err == nil {
+		c.release(node, conn)
+	} else {
+		conn.Close()
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return responses, err
+}
+
+// conn returns an idle connection to node, or dials a new one.
+func (c *wsCaller) conn(ctx context.Context, node string) (*websocket.Conn, error) {
+	c.mu.Lock()
+	if idle := c.idle[node]; len(idle) > 0 {
+		conn := idle[len(idle)-1]
+		c.idle[node] = idle[:len(idle)-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	conn, _, err := c.dialer.DialContext(ctx, "ws://"+node+"/websocket", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial websocket: %w", err)
+	}
+	return conn, nil
+}
+
+// release keeps conn for later calls, unless enough connections are idle.
+func (c *wsCaller) release(node string, conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle[node]) >= c.maxIdle {
+		conn.Close()
+		return
+	}
+	c.idle[node] = append(c.idle[node], conn)
+}
+
+func (c *wsCaller) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, conns := range c.idle {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	c.idle = nil
+	return nil
+}
+
+// roundTrip writes the requests to conn and reads their responses.
+func roundTrip(conn *websocket.Conn, requests []rpctypes.RPCRequest) ([]rpctypes.RPCResponse, error) {
+	for _, req := range requests {
+		if err := conn.WriteJSON(req); err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+	}
+	responses := make([]rpctypes.RPCResponse, 0, len(requests))
+	for range requests {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		resp, err := decodeResponse(msg)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, resp)
+	}
+	return inOrder(requests, responses)
+}
+
+// newRequests returns a broadcast request for each tx, with ids drawn from ids.
+func newRequests(ids *atomic.Int64, method string, txs [][]byte) ([]rpctypes.RPCRequest, error) {
+	requests := make([]rpctypes.RPCRequest, len(txs))
+	for i, tx := range txs {
+		params, err := json.Marshal(map[string][]byte{"tx": tx})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode params: %w", err)
+		}
+		requests[i] = rpctypes.NewRPCRequest(rpctypes.JSONRPCIntID(ids.Add(1)), method, params)
+	}
+	return requests, nil
+}
+
+// inOrder matches responses to requests by id.
+func inOrder(requests []rpctypes.RPCRequest, responses []rpctypes.RPCResponse) ([]rpctypes.RPCResponse, error) {
+	byID := make(map[rpctypes.JSONRPCIntID]rpctypes.RPCResponse, len(responses))
+	for _, resp := range responses {
+		if id, ok := resp.ID.(rpctypes.JSONRPCIntID); ok {
+			byID[id] = resp
+		}
+	}
+
+	ordered := make([]rpctypes.RPCResponse, len(requests))
+	for i, req := range requests {
+		id := req.ID.(rpctypes.JSONRPCIntID)
+		resp, ok := byID[id]
+		if !ok {
+			if len(responses) == 1 && responses[0].Error != nil {
+				// an error not tied to a request, such as a parse error
+				return nil, responses[0].Error
+			}
+			return nil, fmt.Errorf("no response to request %d", id)
+		}
+		ordered[i] = resp
+	}
+	return ordered, nil
+}
@@ -0,0 +1,183 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectedTx is rejected by CheckTx on an rpcNode.
+var rejectedTx = []byte{0xff}
+
+// rpcNode answers broadcast requests over URI, POST and WebSocket JSON-RPC,
+// rejecting rejectedTx in CheckTx and accepting every other tx.
+type rpcNode struct {
+	addr string
+
+	mu       sync.Mutex
+	methods  []string // of the calls received
+	requests int      // HTTP requests and WebSocket connections
+}
+
+func newRPCNode(t *testing.T) *rpcNode {
+	t.Helper()
+	n := &rpcNode{}
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.mu.Lock()
+		n.requests++
+		n.mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/websocket":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var req rpctypes.RPCRequest
+				require.NoError(t, json.Unmarshal(msg, &req))
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, n.respond(t, req)))
+			}
+		case r.Method == http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			if body[0] != '[' {
+				var req rpctypes.RPCRequest
+				require.NoError(t, json.Unmarshal(body, &req))
+				w.Write(n.respond(t, req))
+				return
+			}
+			var reqs []rpctypes.RPCRequest
+			require.NoError(t, json.Unmarshal(body, &reqs))
+			var responses []string
+			for _, req := range reqs {
+				responses = append(responses, string(n.respond(t, req)))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(responses, ","))
+		default:
+			tx, err := hexutil.Decode(r.URL.Query().Get("tx"))
+			require.NoError(t, err)
+			w.Write(n.result(rpctypes.JSONRPCIntID(-1), strings.TrimPrefix(r.URL.Path, "/"), tx))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	n.addr = strings.TrimPrefix(srv.URL, "http://")
+	return n
+}
+
+func (n *rpcNode) respond(t *testing.T, req rpctypes.RPCRequest) []byte {
+	var params struct{ Tx []byte }
+	require.NoError(t, json.Unmarshal(req.Params, &params))
+	return n.result(req.ID.(rpctypes.JSONRPCIntID), req.Method, params.Tx)
+}
+
+func (n *rpcNode) result(id rpctypes.JSONRPCIntID, method string, tx []byte) []byte {
+	n.mu.Lock()
+	n.methods = append(n.methods, method)
+	n.mu.Unlock()
+
+	code := 0
+	if string(tx) == string(rejectedTx) {
+		code = 3
+	}
+	result := fmt.Sprintf(`{"code":%d,"data":"","log":"","codespace":"","hash":"AB"}`, code)
+	if method == "broadcast_tx_commit" {
+		result = fmt.Sprintf(`{"check_tx":{"code":%d},"tx_result":{},"hash":"AB","height":"5"}`, code)
+	}
+	return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":%s}`, id, result))
+}
+
+func (n *rpcNode) stats() ([]string, int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.methods, n.requests
+}
+
+func TestSendModesAndTransports(t *testing.T) {
+	txs := [][]byte{{1}, rejectedTx, {2}}
+	for _, transport := range []Transport{TransportGet, TransportPost, TransportWebSocket} {
+		for _, mode := range []Mode{ModeCommit, ModeSync, ModeAsync} {
+			t.Run(transport.String()+"/"+mode.String(), func(t *testing.T) {
+				node := newRPCNode(t)
+				sender, err := NewSender(1, WithMode(mode), WithTransport(transport))
+				require.NoError(t, err)
+				defer sender.Close()
+
+				for i := 0; i < 2; i++ {
+					results := sender.SendBatch(context.Background(), node.addr, txs)
+					require.Len(t, results, 3)
+					assert.Equal(t, OK, results[0].Outcome, results[0].Err)
+					assert.Equal(t, CheckTxRejected, results[1].Outcome)
+					assert.Equal(t, uint32(3), results[1].Code)
+					assert.Equal(t, OK, results[2].Outcome, results[2].Err)
+				}
+
+				methods, requests := node.stats()
+				assert.Len(t, methods, 6)
+				for _, m := range methods {
+					assert.Equal(t, "broadcast_tx_"+mode.String(), m)
+				}
+				switch transport {
+				case TransportGet:
+					assert.Equal(t, 6, requests)
+				case TransportPost:
+					assert.Equal(t, 2, requests, "a batch is one request")
+				case TransportWebSocket:
+					assert.Equal(t, 1, requests, "the connection is reused")
+				}
+			})
+		}
+	}
+}
+
+func TestParseModeAndTransport(t *testing.T) {
+	for _, s := range []string{"commit", "sync", "async"} {
+		m, err := ParseMode(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, m.String())
+	}
+	for _, s := range []string{"get", "post", "ws"} {
+		tr, err := ParseTransport(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, tr.String())
+	}
+	_, err := ParseMode("fast")
+	assert.Error(t, err)
+	_, err = ParseTransport("grpc")
+	assert.Error(t, err)
+}
+
+func TestRunBatches(t *testing.T) {
+	node := newRPCNode(t)
+	report, err := Run(context.Background(), Config{
+		Nodes:       []string{node.addr},
+		Requests:    12,
+		Concurrency: 2,
+		Mode:        ModeSync,
+		Transport:   TransportPost,
+		Batch:       5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), report.Total.OK)
+	assert.Equal(t, "sync", report.Mode)
+
+	methods, requests := node.stats()
+	assert.Len(t, methods, 12)
+	assert.Equal(t, 3, requests)
+}
@@ -0,0 +1,196 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// defaultNamespace is the namespace of enveloped txs unless others are given.
+const defaultNamespace = "load"
+
+// Format is how payloads are sent as txs.
+type Format int
+
+const (
+	FormatRaw        Format = iota // the payload as opaque tx bytes
+	FormatNamespaced               // an unsigned envelope in a namespace
+	FormatSigned                   // an envelope in a namespace, signed by a producer
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatRaw:
+		return "raw"
+	case FormatNamespaced:
+		return "namespaced"
+	case FormatSigned:
+		return "signed"
+	default:
+		return fmt.Sprintf("format_%d", int(f))
+	}
+}
+
+// ParseFormat parses a tx format: raw, namespaced or signed.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{FormatRaw, FormatNamespaced, FormatSigned} {
+		if s == f.String() {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tx format %q, expected raw, namespaced or signed", s)
+}
+
+// producer is a key signing txs and the nonce of its next tx.
+type producer struct {
+	key   *ecdsa.PrivateKey
+	nonce *uint64 // in the Nonces of the run
+}
+
+// Nonces are the nonces of the next unsigned envelope and of each producer's
+// next tx. The phases of a scenario share them, so that a later phase doesn't
+// repeat the txs of an earlier one, which nodes would reject as duplicates.
+//
+// Nonces start at the time they were created, in nanoseconds since the epoch,
+// rather than 0: a later run with the same keys then continues above the
+// nonces of earlier ones, without reading them back from the stream, as long
+// as no run sends more txs per producer than nanoseconds pass until the next.
+type Nonces struct {
+	start     uint64
+	envelope  atomic.Uint64
+	mu        sync.Mutex
+	producers map[common.Address]*uint64
+}
+
+// NewNonces returns nonces starting at the current time.
+func NewNonces() *Nonces {
+	n := &Nonces{start: uint64(time.Now().UnixNano()), producers: make(map[common.Address]*uint64)}
+	n.envelope.Store(n.start)
+	return n
+}
+
+// producer returns the nonce of the next tx of the producer at addr.
+func (n *Nonces) producer(addr common.Address) *uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	nonce, ok := n.producers[addr]
+	if !ok {
+		nonce = new(uint64)
+		*nonce = n.start
+		n.producers[addr] = nonce
+	}
+	return nonce
+}
+
+// txSource makes the txs of a load test from its payloads. Unsigned envelopes
+// get increasing nonces, so that replayed payloads still make distinct txs.
+// Signed envelopes get the nonces of their producer.
+type txSource struct {
+	payloads   Payloads
+	format     Format
+	namespaces []string
+	producers  chan *producer // producers with no txs in flight
+	addresses  []common.Address
+	nonces     *Nonces
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newTxSource(cfg Config) (*txSource, error) {
+	s := &txSource{
+		payloads:   cfg.Payloads,
+		format:     cfg.Format,
+		namespaces: cfg.Namespaces,
+		nonces:     cfg.Nonces,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if s.nonces == nil {
+		s.nonces = NewNonces()
+	}
+	if s.payloads == nil {
+		s.payloads = RandomPayloads(FixedSize(defaultPayloadSize))
+	}
+	if len(s.namespaces) == 0 {
+		s.namespaces = []string{defaultNamespace}
+	}
+	if s.format != FormatSigned {
+		return s, nil
+	}
+
+	keys := cfg.Keys
+	for len(keys) < cfg.producers() {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate producer key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	s.producers = make(chan *producer, len(keys))
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		s.producers <- &producer{key: key, nonce: s.nonces.producer(addr)}
+		s.addresses = append(s.addresses, addr)
+	}
+	return s, nil
+}
+
+// take makes n txs. Signed txs are all from one producer, which has no other
+// txs in flight until release is called, so that each producer's txs are sent
+// in nonce order.
+func (s *txSource) take(ctx context.Context, n int) (txs [][]byte, release func(), err error) {
+	release = func() {}
+	var p *producer
+	if s.producers != nil {
+		select {
+		case p = <-s.producers:
+		case <-ctx.Done():
+			return nil, release, ctx.Err()
+		}
+		release = func() { s.producers <- p }
+	}
+
+	txs = make([][]byte, n)
+	for i := range txs {
+		if txs[i], err = s.next(p); err != nil {
+			release()
+			return nil, func() {}, err
+		}
+	}
+	return txs, release, nil
+}
+
+// next makes a tx, signed by p if the format is signed.
+func (s *txSource) next(p *producer) ([]byte, error) {
+	payload, err := s.payloads.Next()
+	if err != nil {
+		return nil, err
+	}
+	if s.format == FormatRaw {
+		return payload, nil
+	}
+
+	s.mu.Lock()
+	namespace := s.namespaces[s.rand.Intn(len(s.namespaces))]
+	s.mu.Unlock()
+	e := &app.Envelope{Namespace: namespace, Payload: payload}
+	if p == nil {
+		e.Nonce = s.nonces.envelope.Add(1) - 1
+		return e.Encode()
+	}
+
+	e.Nonce = *p.nonce
+	if err := e.Sign(p.key); err != nil {
+		return nil, err
+	}
+	*p.nonce++
+	return e.Encode()
+}
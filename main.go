@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/christophercampbell/dseq/app"
 	"github.com/christophercampbell/dseq/cmd"
@@ -14,10 +15,22 @@ const (
 	AppName = "dseq"
 )
 
+// Set at link time, as the Makefile does with -ldflags.
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = ""
+)
+
 func main() {
 	cliApp := cli.NewApp()
 	cliApp.Name = AppName
 	cliApp.Version = fmt.Sprintf("%v", app.AppVersion)
+	cliApp.Metadata = map[string]interface{}{
+		"version":    Version,
+		"git_commit": GitCommit,
+		"build_time": BuildTime,
+	}
 
 	cliApp.Commands = []*cli.Command{
 		{
@@ -32,21 +45,104 @@ func main() {
 				},
 				&cli.UintFlag{
 					Name:     "port",
-					Usage:    "Data stream server port (6900)",
+					Usage:    "Data stream server port, overriding dseq.stream_port in config.toml (6900)",
 					Required: false,
-					Value:    6900,
+				},
+				&cli.StringFlag{
+					Name:  "gateway",
+					Usage: "Serve the stream over WebSocket and SSE on this address (e.g. :8080), overriding dseq.gateway in config.toml",
+				},
+				&cli.StringFlag{
+					Name:  "health",
+					Usage: "Serve /healthz and /readyz on this address, or nowhere if empty, overriding dseq.health in config.toml (:26680)",
+				},
+			},
+		}, {
+			Name:   "init",
+			Usage:  "Create the home directory of a new single-validator node",
+			Action: cmd.InitNode,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "home",
+					Usage:    "Home directory `DIR` to create",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "moniker",
+					Usage:    "Name of the node",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "chain-id",
+					Usage:    "ID of the new chain",
+					Required: true,
+				},
+			},
+		}, {
+			Name:   "testnet",
+			Usage:  "Generate the home directories of a local multi-node network, and scripts to run it",
+			Action: cmd.GenerateTestnet,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "validators",
+					Usage: "Number of validator nodes",
+					Value: 4,
+				},
+				&cli.StringFlag{
+					Name:     "output",
+					Aliases:  []string{"o"},
+					Usage:    "Directory `DIR` to create the node homes in",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "chain-id",
+					Usage: "ID of the new chain",
+					Value: "dseq-testnet",
+				},
+				&cli.IntFlag{
+					Name:  "base-port",
+					Usage: "P2P port of the first node; its RPC port is the next, and each node's ports are 10 above the last's",
+					Value: 26656,
+				},
+				&cli.IntFlag{
+					Name:  "stream-port",
+					Usage: "Data stream port of the first node; each node's is 1 above the last's",
+					Value: 6900,
 				},
 			},
 		}, {
 			Name:   "load",
 			Usage:  "Send test transaction requests",
 			Action: cmd.RunLoad,
+			Subcommands: []*cli.Command{
+				{
+					Name:      "compare",
+					Usage:     "Compare the JSON reports of two load runs and flag regressions",
+					ArgsUsage: "OLD.json NEW.json",
+					Action:    cmd.RunLoadCompare,
+					Flags: []cli.Flag{
+						&cli.Float64Flag{
+							Name:  "tolerance",
+							Usage: "Largest relative increase in latency, or decrease in throughput, that is not a regression",
+							Value: 0.1,
+						},
+						&cli.Float64Flag{
+							Name:  "error-tolerance",
+							Usage: "Largest increase in the fraction of txs not OK that is not a regression",
+							Value: 0.001,
+						},
+					},
+				},
+			},
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:     "nodes",
-					Aliases:  []string{"n"},
-					Usage:    "Host(&port) of nodes to send load requests, inCSV format",
-					Required: true,
+					Name:    "nodes",
+					Aliases: []string{"n"},
+					Usage:   "Host(&port) of nodes to send load requests, inCSV format",
+				},
+				&cli.StringFlag{
+					Name:  "scenario",
+					Usage: "YAML file describing the phases of the test, their rates and thresholds",
 				},
 				&cli.UintFlag{
 					Name:    "requests",
@@ -60,6 +156,99 @@ func main() {
 					Usage:   "Number of concurrent requests",
 					Value:   1,
 				},
+				&cli.Float64Flag{
+					Name:  "rate",
+					Usage: "Send txs open-loop at this many per second, instead of a number of requests",
+				},
+				&cli.DurationFlag{
+					Name:  "duration",
+					Usage: "How long to hold the rate",
+				},
+				&cli.DurationFlag{
+					Name:  "ramp-up",
+					Usage: "Ramp up linearly to the rate over this long first",
+				},
+				&cli.StringFlag{
+					Name:  "stages",
+					Usage: "Open-loop stages as RATE:DURATION or FROM-TO:DURATION, in CSV format (e.g. 0-100:30s,100:5m)",
+				},
+				&cli.UintFlag{
+					Name:  "max-in-flight",
+					Usage: "Maximum txs in flight in an open-loop test",
+					Value: 1000,
+				},
+				&cli.StringFlag{
+					Name:  "streams",
+					Usage: "Data streams (host:port) to measure submit-to-stream latency on, in CSV format",
+				},
+				&cli.DurationFlag{
+					Name:  "stream-wait",
+					Usage: "How long to wait for committed txs to reach the streams after sending",
+					Value: 10 * time.Second,
+				},
+				&cli.BoolFlag{
+					Name:  "audit",
+					Usage: "Check the streams sequenced every accepted tx exactly once, in the same order",
+				},
+				&cli.StringFlag{
+					Name:  "payload-size",
+					Usage: "Size distribution of random payloads: SIZE, uniform:MIN-MAX or zipf:MIN-MAX[:S]",
+					Value: "32",
+				},
+				&cli.StringFlag{
+					Name:  "replay",
+					Usage: "Replay the lines of a JSONL file as payloads, instead of random ones",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "How payloads are sent: raw, namespaced or signed",
+					Value: "raw",
+				},
+				&cli.StringFlag{
+					Name:  "namespaces",
+					Usage: "Namespaces of enveloped txs, picked at random, in CSV format",
+					Value: "load",
+				},
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "Hex private key of a producer signing txs of the signed format",
+				},
+				&cli.StringFlag{
+					Name:  "keystore",
+					Usage: "Keystore directory with the keys of producers signing txs of the signed format",
+				},
+				&cli.StringFlag{
+					Name:  "password-file",
+					Usage: "File with the password of the keystore",
+				},
+				&cli.UintFlag{
+					Name:  "producers",
+					Usage: "Producers signing txs of the signed format, generating keys for those not given",
+					Value: 1,
+				},
+				&cli.StringFlag{
+					Name:  "mode",
+					Usage: "Broadcast mode: commit, sync or async",
+					Value: "commit",
+				},
+				&cli.StringFlag{
+					Name:  "transport",
+					Usage: "How requests reach the nodes: get, post or ws",
+					Value: "get",
+				},
+				&cli.UintFlag{
+					Name:  "batch",
+					Usage: "Txs per request with the post or ws transport",
+					Value: 1,
+				},
+				&cli.StringFlag{
+					Name:  "report",
+					Usage: "Also write a machine-readable report: json, csv or prometheus-textfile",
+				},
+				&cli.StringFlag{
+					Name:  "report-file",
+					Usage: "File to write the --report to, instead of stdout",
+				},
 			},
 		}, {
 			Name:   "read",
@@ -77,6 +266,194 @@ func main() {
 					Required: false,
 					Value:    0,
 				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Output format: table, json, ndjson, or raw (length-prefixed tx bytes)",
+					Value: "table",
+				},
+				&cli.Uint64Flag{
+					Name:  "to",
+					Usage: "Last entry number to read",
+				},
+				&cli.Uint64Flag{
+					Name:  "to-height",
+					Usage: "Last block height to read",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Usage: "Number of entries to print before exiting",
+				},
+				&cli.StringSliceFlag{
+					Name:  "type",
+					Usage: "Only print entries of these types (bookmark, block_start, tx, block_end)",
+				},
+				&cli.StringFlag{
+					Name:  "namespace",
+					Usage: "Only print txs with this envelope namespace",
+				},
+				&cli.StringFlag{
+					Name:  "producer",
+					Usage: "Only print txs with this envelope producer address",
+				},
+				&cli.BoolFlag{
+					Name:  "verify",
+					Usage: "Verify block framing, tx roots and app hashes while reading, with --rpc",
+				},
+				&cli.StringFlag{
+					Name:  "rpc",
+					Usage: "CometBFT RPC address of the node to compare app hashes with (http://host:port), required by --verify",
+				},
+				&cli.StringFlag{
+					Name:  "checkpoint",
+					Usage: "File to save the read position to, and resume from if it exists",
+				},
+			},
+		}, {
+			Name:   "relay",
+			Usage:  "Mirror a node's data stream and serve it to readers",
+			Action: cmd.RunRelay,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "upstream",
+					Usage:    "Node to mirror the data stream from (host:port)",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "home",
+					Usage:    "Directory `DIR` for the mirrored stream file",
+					Required: true,
+				},
+				&cli.UintFlag{
+					Name:     "port",
+					Usage:    "Data stream server port (6900)",
+					Required: false,
+					Value:    6900,
+				},
+			},
+		}, {
+			Name:   "compare",
+			Usage:  "Compare the data streams of several nodes entry by entry",
+			Action: cmd.CompareStreams,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "nodes",
+					Aliases:  []string{"n"},
+					Usage:    "Data stream addresses of the nodes to compare (host:port), in CSV format",
+					Required: true,
+				},
+				&cli.Uint64Flag{
+					Name:  "from",
+					Usage: "Entry to start comparing from",
+				},
+				&cli.BoolFlag{
+					Name:  "follow",
+					Usage: "Keep comparing new entries until the nodes diverge or the command is stopped",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Usage: "How often to report progress and node lag with --follow",
+					Value: 10 * time.Second,
+				},
+			},
+		}, {
+			Name:      "inspect",
+			Usage:     "Inspect a data stream file without a stream server",
+			ArgsUsage: "FILE",
+			Action:    cmd.InspectFile,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "bookmarks",
+					Usage: "List every bookmark in the summary",
+				},
+				&cli.BoolFlag{
+					Name:  "dump",
+					Usage: "Print entries instead of a summary, bounded and filtered like read",
+				},
+				&cli.Uint64Flag{
+					Name:  "entry",
+					Usage: "Print the entry with this number",
+				},
+				&cli.Uint64Flag{
+					Name:  "from",
+					Usage: "First entry number to print",
+				},
+				&cli.Uint64Flag{
+					Name:  "to",
+					Usage: "Last entry number to print",
+				},
+				&cli.Uint64Flag{
+					Name:  "from-height",
+					Usage: "First block height to print",
+				},
+				&cli.Uint64Flag{
+					Name:  "to-height",
+					Usage: "Last block height to print",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Usage: "Number of entries to print",
+				},
+				&cli.StringSliceFlag{
+					Name:  "type",
+					Usage: "Only print entries of these types (bookmark, block_start, tx, block_end)",
+				},
+				&cli.StringFlag{
+					Name:  "namespace",
+					Usage: "Only print txs with this envelope namespace",
+				},
+				&cli.StringFlag{
+					Name:  "producer",
+					Usage: "Only print txs with this envelope producer address",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Output format: table, json, ndjson, or raw (length-prefixed tx bytes)",
+					Value: "table",
+				},
+			},
+		}, {
+			Name:   "export-sql",
+			Usage:  "Export the sequence to a SQLite database, resuming after the last exported block",
+			Action: cmd.ExportSQL,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    "SQLite database `FILE` to export to",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "Stream file to export once",
+				},
+				&cli.StringFlag{
+					Name:  "node",
+					Usage: "Node to export the data stream from continuously (host:port)",
+				},
+				&cli.UintFlag{
+					Name:  "batch",
+					Usage: "Number of blocks to write per transaction",
+					Value: 1000,
+				},
+			},
+		}, {
+			Name:   "gateway",
+			Usage:  "Serve a node's data stream over WebSocket and SSE",
+			Action: cmd.RunGateway,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "node",
+					Usage:    "Node to read data stream from (host:port)",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "listen",
+					Usage: "HTTP address to serve the gateway on",
+					Value: ":8080",
+				},
+				&cli.StringSliceFlag{
+					Name:  "allowed-origin",
+					Usage: "Origin of web pages allowed to open streams, besides the gateway's own (repeatable, * for any)",
+				},
 			},
 		},
 	}
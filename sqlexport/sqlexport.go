@@ -0,0 +1,309 @@
+// Package sqlexport writes the blocks of the sequence into a SQLite database for
+// ad-hoc SQL queries. Exports are incremental: the database records the entry
+// after the last exported block, and Next tells where to resume reading.
+package sqlexport
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/christophercampbell/dseq/client"
+	"github.com/ethereum/go-ethereum/common"
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+)
+
+// SchemaVersion is the version of the schema created by Open.
+const SchemaVersion = 1
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS namespaces (
+	id   INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS producers (
+	id      INTEGER PRIMARY KEY,
+	address TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS blocks (
+	height      INTEGER PRIMARY KEY,
+	time        TEXT NOT NULL,
+	hash        TEXT NOT NULL,
+	num_txs     INTEGER NOT NULL,
+	size        INTEGER NOT NULL,
+	first_entry INTEGER NOT NULL,
+	last_entry  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS blocks_time ON blocks (time);
+
+CREATE TABLE IF NOT EXISTS txs (
+	entry        INTEGER PRIMARY KEY,
+	height       INTEGER NOT NULL REFERENCES blocks (height),
+	position     INTEGER NOT NULL,
+	hash         TEXT NOT NULL,
+	size         INTEGER NOT NULL,
+	data         BLOB NOT NULL,
+	namespace_id INTEGER REFERENCES namespaces (id),
+	producer_id  INTEGER REFERENCES producers (id),
+	nonce        INTEGER,
+	payload      BLOB,
+	signature    BLOB
+);
+CREATE INDEX IF NOT EXISTS txs_height ON txs (height, position);
+CREATE INDEX IF NOT EXISTS txs_hash ON txs (hash);
+CREATE INDEX IF NOT EXISTS txs_namespace ON txs (namespace_id, height);
+CREATE INDEX IF NOT EXISTS txs_producer ON txs (producer_id, nonce);
+
+CREATE VIEW IF NOT EXISTS txs_view AS
+SELECT t.entry, t.height, b.time, t.position, t.hash, t.size,
+       n.name AS namespace, p.address AS producer, t.nonce, t.payload
+FROM txs t
+JOIN blocks b ON b.height = t.height
+LEFT JOIN namespaces n ON n.id = t.namespace_id
+LEFT JOIN producers p ON p.id = t.producer_id;
+`
+
+// DB is a SQLite database the sequence is exported to. Blocks are written in
+// transactions that are committed by Flush.
+type DB struct {
+	db *sql.DB
+	tx *sql.Tx
+
+	next       uint64 // entry after the last added block
+	flushed    uint64 // entry after the last committed block
+	pending    int    // blocks added since the last flush
+	pendingTxs int    // txs of the pending blocks
+	blocks     int    // blocks committed since Open
+	txs        int    // txs committed since Open
+	namespaces map[string]int64
+	producers  map[common.Address]int64
+}
+
+// Open opens or creates the export database at path.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// a single connection keeps the pragmas and the open transaction together
+	db.SetMaxOpenConns(1)
+
+	d := &DB{
+		db:         db,
+		namespaces: make(map[string]int64),
+		producers:  make(map[common.Address]int64),
+	}
+	if err := d.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DB) init() error {
+	for _, pragma := range []string{"journal_mode = WAL", "synchronous = NORMAL", "foreign_keys = ON"} {
+		if _, err := d.db.Exec("PRAGMA " + pragma); err != nil {
+			return fmt.Errorf("failed to set %s: %w", pragma, err)
+		}
+	}
+	if _, err := d.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	version, err := d.meta("schema_version")
+	if err != nil {
+		return err
+	}
+	switch version {
+	case "":
+		if _, err := d.db.Exec(`INSERT INTO meta (key, value) VALUES ('schema_version', ?), ('next_entry', '0')`, strconv.Itoa(SchemaVersion)); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+	case strconv.Itoa(SchemaVersion):
+	default:
+		return fmt.Errorf("database has schema version %s, expected %d", version, SchemaVersion)
+	}
+
+	next, err := d.meta("next_entry")
+	if err != nil {
+		return err
+	}
+	if d.next, err = strconv.ParseUint(next, 10, 64); err != nil {
+		return fmt.Errorf("invalid next_entry %q", next)
+	}
+	d.flushed = d.next
+	return nil
+}
+
+func (d *DB) meta(key string) (string, error) {
+	var value string
+	err := d.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// Next returns the stream entry to resume exporting from.
+func (d *DB) Next() uint64 {
+	return d.next
+}
+
+// Pending returns the number of blocks added but not yet flushed.
+func (d *DB) Pending() int {
+	return d.pending
+}
+
+// Exported returns the number of blocks and txs committed since Open. Blocks
+// that Add skipped are not counted.
+func (d *DB) Exported() (blocks, txs int) {
+	return d.blocks, d.txs
+}
+
+// Add writes a block and its txs. Blocks that end before Next were already
+// exported and are skipped, so replaying part of the stream is harmless. If Add
+// fails, every block added since the last flush is discarded.
+func (d *DB) Add(b *client.Block) error {
+	if b.LastEntry < d.next {
+		return nil
+	}
+	if err := d.add(b); err != nil {
+		d.rollback()
+		return err
+	}
+	return nil
+}
+
+func (d *DB) add(b *client.Block) error {
+	if d.tx == nil {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		d.tx = tx
+	}
+
+	if _, err := d.tx.Exec(
+		`INSERT INTO blocks (height, time, hash, num_txs, size, first_entry, last_entry) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		b.Height, b.Time.UTC().Format(time.RFC3339Nano), b.Hash.Hex(), len(b.Txs), b.Size, b.FirstEntry, b.LastEntry,
+	); err != nil {
+		return fmt.Errorf("failed to insert block %d: %w", b.Height, err)
+	}
+
+	for i, tx := range b.Txs {
+		var namespace, producer, nonce sql.NullInt64
+		var payload, signature []byte
+		if env := tx.Envelope; env != nil {
+			id, err := d.namespaceID(env.Namespace)
+			if err != nil {
+				return err
+			}
+			namespace = sql.NullInt64{Int64: id, Valid: true}
+			if id, err = d.producerID(env.Producer); err != nil {
+				return err
+			}
+			producer = sql.NullInt64{Int64: id, Valid: true}
+			nonce = sql.NullInt64{Int64: int64(env.Nonce), Valid: true}
+			payload, signature = env.Payload, env.Signature
+		}
+		if _, err := d.tx.Exec(
+			`INSERT INTO txs (entry, height, position, hash, size, data, namespace_id, producer_id, nonce, payload, signature)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tx.Entry, b.Height, i, tx.Hash.Hex(), len(tx.Data), []byte(tx.Data), namespace, producer, nonce, payload, signature,
+		); err != nil {
+			return fmt.Errorf("failed to insert tx at entry %d: %w", tx.Entry, err)
+		}
+	}
+
+	next := b.LastEntry + 1
+	if _, err := d.tx.Exec(`UPDATE meta SET value = ? WHERE key = 'next_entry'`, strconv.FormatUint(next, 10)); err != nil {
+		return fmt.Errorf("failed to update next entry: %w", err)
+	}
+	d.next = next
+	d.pending++
+	d.pendingTxs += len(b.Txs)
+	return nil
+}
+
+// namespaceID returns the id of a namespace, inserting it if it is new.
+func (d *DB) namespaceID(name string) (int64, error) {
+	if id, ok := d.namespaces[name]; ok {
+		return id, nil
+	}
+	id, err := d.lookup(`INSERT INTO namespaces (name) VALUES (?) ON CONFLICT (name) DO UPDATE SET name = name RETURNING id`, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert namespace %q: %w", name, err)
+	}
+	d.namespaces[name] = id
+	return id, nil
+}
+
+// producerID returns the id of a producer, inserting it if it is new.
+func (d *DB) producerID(addr common.Address) (int64, error) {
+	if id, ok := d.producers[addr]; ok {
+		return id, nil
+	}
+	id, err := d.lookup(`INSERT INTO producers (address) VALUES (?) ON CONFLICT (address) DO UPDATE SET address = address RETURNING id`, addr.Hex())
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert producer %s: %w", addr, err)
+	}
+	d.producers[addr] = id
+	return id, nil
+}
+
+func (d *DB) lookup(query string, arg string) (int64, error) {
+	var id int64
+	err := d.tx.QueryRow(query, arg).Scan(&id)
+	return id, err
+}
+
+// Flush commits the blocks added since the last flush.
+func (d *DB) Flush() error {
+	if d.tx == nil {
+		return nil
+	}
+	if err := d.tx.Commit(); err != nil {
+		d.rollback()
+		return fmt.Errorf("failed to commit export: %w", err)
+	}
+	d.tx = nil
+	d.flushed = d.next
+	d.blocks += d.pending
+	d.txs += d.pendingTxs
+	d.pending, d.pendingTxs = 0, 0
+	return nil
+}
+
+// rollback discards the blocks added since the last flush.
+func (d *DB) rollback() {
+	if d.tx != nil {
+		_ = d.tx.Rollback()
+		d.tx = nil
+	}
+	d.next = d.flushed
+	d.pending, d.pendingTxs = 0, 0
+	// ids cached during the transaction may have been rolled back
+	d.namespaces = make(map[string]int64)
+	d.producers = make(map[common.Address]int64)
+}
+
+// Close flushes pending blocks and closes the database.
+func (d *DB) Close() error {
+	err := d.Flush()
+	if closeErr := d.db.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package sqlexport
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/christophercampbell/dseq/app"
+	"github.com/christophercampbell/dseq/client"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBlock returns a block with an opaque tx and a tx in namespace ns,
+// occupying entries first to first+4.
+func testBlock(height, first uint64, ns string) *client.Block {
+	env := &app.Envelope{Namespace: ns, Producer: common.HexToAddress("0x01"), Nonce: height, Payload: []byte("payload")}
+	data, _ := env.Encode()
+	return &client.Block{
+		Height: height,
+		Time:   time.Unix(int64(height), 0),
+		Txs: []client.Tx{
+			{Entry: first + 2, Hash: app.TxHash([]byte("opaque")), Data: []byte("opaque")},
+			{Entry: first + 3, Hash: app.TxHash(data), Data: data, Envelope: env},
+		},
+		Size:       height * 2,
+		FirstEntry: first,
+		LastEntry:  first + 4,
+	}
+}
+
+func count(t *testing.T, path, query string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	var n int
+	require.NoError(t, db.QueryRow(query).Scan(&n))
+	return n
+}
+
+func TestExportResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.sqlite")
+
+	d, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), d.Next())
+	require.NoError(t, d.Add(testBlock(1, 0, "a")))
+	require.NoError(t, d.Add(testBlock(2, 5, "b")))
+	assert.Equal(t, 2, d.Pending())
+	require.NoError(t, d.Close())
+
+	d, err = Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), d.Next())
+
+	// replayed blocks are skipped
+	require.NoError(t, d.Add(testBlock(2, 5, "b")))
+	require.NoError(t, d.Add(testBlock(3, 10, "a")))
+	require.NoError(t, d.Flush())
+	blocks, txs := d.Exported()
+	assert.Equal(t, 1, blocks)
+	assert.Equal(t, 2, txs)
+	require.NoError(t, d.Close())
+
+	assert.Equal(t, 3, count(t, path, `SELECT COUNT(*) FROM blocks`))
+	assert.Equal(t, 6, count(t, path, `SELECT COUNT(*) FROM txs`))
+	assert.Equal(t, 2, count(t, path, `SELECT COUNT(*) FROM namespaces`))
+	assert.Equal(t, 1, count(t, path, `SELECT COUNT(*) FROM producers`))
+	assert.Equal(t, 2, count(t, path, `SELECT COUNT(*) FROM txs_view WHERE namespace = 'a'`))
+}
+
+func TestExportFailureDiscardsPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.sqlite")
+
+	d, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, d.Add(testBlock(1, 0, "a")))
+	require.NoError(t, d.Flush())
+
+	require.NoError(t, d.Add(testBlock(2, 5, "a")))
+	// a second block at the same height can't be inserted
+	require.Error(t, d.Add(testBlock(2, 10, "a")))
+	assert.Equal(t, uint64(5), d.Next())
+	assert.Equal(t, 0, d.Pending())
+	blocks, _ := d.Exported()
+	assert.Equal(t, 1, blocks, "discarded blocks are not counted")
+
+	require.NoError(t, d.Add(testBlock(2, 5, "a")))
+	require.NoError(t, d.Close())
+	assert.Equal(t, 2, count(t, path, `SELECT COUNT(*) FROM blocks`))
+}